@@ -0,0 +1,170 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/percona/mongodb_exporter/exporter"
+)
+
+const customQueriesKey = "custom_queries"
+
+// yamlConfig is a kong configuration loader for YAML files. The keys are the flag names, like
+// enable.dbstats, and they can be nested by their dot separated parts:
+//
+//	mongodb:
+//	  uri: mongodb://127.0.0.1:27017
+//	  collstats-colls: [db1.col1, db1.col2]
+//	enable.dbstats: true
+//
+// Lists are joined with commas, for the flags with comma separated values. The custom_queries key
+// is not a flag, it's read by loadCustomQueries.
+func yamlConfig(r io.Reader) (kong.Resolver, error) {
+	var doc map[interface{}]interface{}
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "cannot parse the config file")
+	}
+
+	delete(doc, customQueriesKey)
+
+	values := map[string]interface{}{}
+	if err := flattenConfig("", doc, values); err != nil {
+		return nil, err
+	}
+
+	return &configResolver{values: values}, nil
+}
+
+func flattenConfig(prefix string, doc map[interface{}]interface{}, values map[string]interface{}) error {
+	for k, v := range doc {
+		key := fmt.Sprint(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := v.(type) {
+		case map[interface{}]interface{}:
+			if err := flattenConfig(key, v, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+
+			values[key] = strings.Join(items, ",")
+		case nil:
+			return errors.Errorf("config file key %q has no value", key)
+		default:
+			values[key] = v
+		}
+	}
+
+	return nil
+}
+
+type configResolver struct {
+	values map[string]interface{}
+}
+
+// Validate rejects the unknown keys, to catch typos.
+func (c *configResolver) Validate(app *kong.Application) error {
+	flags := map[string]bool{}
+	for _, group := range app.AllFlags(false) {
+		for _, flag := range group {
+			flags[flag.Name] = true
+		}
+	}
+
+	var unknown []string
+	for key := range c.values {
+		if !flags[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("unknown config file keys: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Resolve returns the value of the flag from the config file. The flags set in the command line
+// are not resolved, so they take precedence.
+func (c *configResolver) Resolve(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (interface{}, error) {
+	v, ok := c.values[flag.Name]
+	if !ok {
+		return nil, nil
+	}
+
+	// The string flags don't accept other YAML scalars, like the numeric database names.
+	if flag.Target.Kind() == reflect.String {
+		return fmt.Sprint(v), nil
+	}
+
+	return v, nil
+}
+
+// loadCustomQueries returns the custom_queries of the config file, like:
+//
+//	custom_queries:
+//	  - name: orders
+//	    database: shop
+//	    collection: orders
+//	    pipeline: '[{"$group": {"_id": "$status", "count": {"$sum": 1}}}]'
+//	    labels:
+//	      status: _id
+//	    metrics:
+//	      - field: count
+//	        name: mongodb_shop_orders
+//	        help: Number of orders by status
+func loadCustomQueries(path string) ([]exporter.CustomQuery, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the config file")
+	}
+
+	var config struct {
+		CustomQueries []exporter.CustomQuery `yaml:"custom_queries"`
+	}
+
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, errors.Wrap(err, "cannot parse the custom queries of the config file")
+	}
+
+	return config.CustomQueries, nil
+}
+
+var _ kong.Resolver = (*configResolver)(nil)
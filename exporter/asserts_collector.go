@@ -0,0 +1,85 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// assertsCollector exposes the number of assertions raised by type from serverStatus().asserts.
+type assertsCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	// The compatible mode already exposes mongodb_asserts_total from the diagnostic data so,
+	// it must not be exposed twice.
+	compatibleMode bool
+	logger         *logrus.Logger
+	topologyInfo   labelsGetter
+}
+
+func (d *assertsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *assertsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *assertsCollector) Collect(ch chan<- prometheus.Metric) {
+	if d.compatibleMode {
+		return
+	}
+
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range assertsMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// assertsMetrics builds the counters since the server started. The server resets all of them to
+// zero when one reaches 2^30 and increments the rollovers counter so, like on a restart, rate()
+// handles the reset.
+func assertsMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := make([]fieldMetric, 0, 5) //nolint:gomnd
+
+	for _, t := range []string{"regular", "warning", "msg", "user", "rollovers"} {
+		defs = append(defs, fieldMetric{
+			path:   []string{"asserts", t},
+			name:   "mongodb_asserts_total",
+			help:   "Number of assertions raised since the server started, by type",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": t},
+		})
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*assertsCollector)(nil)
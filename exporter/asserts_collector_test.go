@@ -0,0 +1,70 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAssertsMetrics(t *testing.T) {
+	m := bson.M{
+		"asserts": bson.M{
+			"regular":   int32(0),
+			"warning":   int32(1),
+			"msg":       int32(2),
+			"user":      int32(57),
+			"tripwire":  int32(0),
+			"rollovers": int32(0),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_asserts_total Number of assertions raised since the server started, by type",
+		"# TYPE mongodb_asserts_total counter",
+		`mongodb_asserts_total{rs_nm="rs1",type="msg"} 2`,
+		`mongodb_asserts_total{rs_nm="rs1",type="regular"} 0`,
+		`mongodb_asserts_total{rs_nm="rs1",type="rollovers"} 0`,
+		`mongodb_asserts_total{rs_nm="rs1",type="user"} 57`,
+		`mongodb_asserts_total{rs_nm="rs1",type="warning"} 1`,
+	}
+
+	metrics := assertsMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
+
+func TestAssertsCollectorCompatibleMode(t *testing.T) {
+	m := bson.M{"serverStatus": bson.M{"asserts": bson.M{"regular": int32(0), "user": int32(57)}}}
+
+	// The compatible mode metrics of getDiagnosticData have mongodb_asserts_total.
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(&fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+		for _, metric := range makeMetrics("", m, map[string]string{}, true, nil) {
+			ch <- metric
+		}
+	}}))
+	require.NoError(t, reg.Register(&assertsCollector{compatibleMode: true, logger: logrus.New()}))
+
+	_, err := reg.Gather()
+	assert.NoError(t, err)
+}
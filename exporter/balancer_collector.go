@@ -0,0 +1,171 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// balancerCollector exposes the state of the shard balancer. It only works through a mongos.
+type balancerCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+// Changelog events of the migrations and the result label used for them.
+var migrationResults = map[string]string{ //nolint:gochecknoglobals
+	"moveChunk.commit": "success",
+	"moveChunk.error":  "failed",
+}
+
+func (d *balancerCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *balancerCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *balancerCollector) Collect(ch chan<- prometheus.Metric) {
+	labels := d.topologyInfo.baseLabels()
+
+	// If the balancer was never stopped, there is no document in config.settings.
+	var settings bson.M
+
+	err := d.client.Database("config").Collection("settings").FindOne(d.ctx, bson.M{"_id": "balancer"}).Decode(&settings)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		d.logger.Errorf("cannot get the balancer settings: %s", err)
+		d.fail()
+	} else {
+		ch <- balancerEnabledMetric(settings, labels)
+	}
+
+	var status bson.M
+
+	cmd := bson.D{{Key: "balancerStatus", Value: 1}}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&status); err != nil {
+		d.logger.Errorf("cannot get balancerStatus: %s", err)
+		d.fail()
+	} else {
+		ch <- balancerRunningMetric(status, labels)
+	}
+
+	events := make([]string, 0, len(migrationResults))
+	for event := range migrationResults {
+		events = append(events, event)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"what": bson.M{"$in": events}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$what", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := d.client.Database("config").Collection("changelog").Aggregate(d.ctx, pipeline)
+	if err != nil {
+		d.logger.Errorf("cannot aggregate the sharding changelog: %s", err)
+		d.fail()
+
+		return
+	}
+
+	var groups []bson.M
+	if err := cursor.All(d.ctx, &groups); err != nil {
+		d.logger.Errorf("cannot aggregate the sharding changelog: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range balancerMigrationsMetrics(groups, labels) {
+		ch <- metric
+	}
+}
+
+// balancerEnabledMetric receives the balancer document from config.settings, nil if it doesn't exist.
+func balancerEnabledMetric(settings bson.M, labels map[string]string) prometheus.Metric {
+	enabled := 1.0
+
+	if stopped, ok := settings["stopped"].(bool); ok && stopped {
+		enabled = 0
+	}
+
+	// Since MongoDB 3.4, sh.stopBalancer() also sets the mode.
+	if mode, ok := settings["mode"].(string); ok && mode == "off" {
+		enabled = 0
+	}
+
+	d := prometheus.NewDesc("mongodb_balancer_enabled", "Whether the shard balancer is enabled", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, enabled)
+}
+
+func balancerRunningMetric(status bson.M, labels map[string]string) prometheus.Metric {
+	running := 0.0
+	if inRound, ok := status["inBalancerRound"].(bool); ok && inRound {
+		running = 1
+	}
+
+	d := prometheus.NewDesc("mongodb_balancer_running", "Whether the shard balancer is in a balancing round", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, running)
+}
+
+// balancerMigrationsMetrics builds the migration counts from the config.changelog events grouped by type.
+// Since config.changelog is a capped collection, they reflect the migrations currently in it, so they
+// are gauges, not counters.
+func balancerMigrationsMetrics(groups []bson.M, labels map[string]string) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(groups))
+
+	for _, group := range groups {
+		event, _ := group["_id"].(string)
+
+		result, ok := migrationResults[event]
+		if !ok {
+			continue
+		}
+
+		count, err := asFloat64(group["count"])
+		if err != nil || count == nil {
+			continue
+		}
+
+		migrationLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			migrationLabels[k] = v
+		}
+
+		migrationLabels["result"] = result
+
+		d := prometheus.NewDesc("mongodb_balancer_migrations",
+			"Number of chunk migrations currently in the config.changelog capped collection", nil, migrationLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *count))
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*balancerCollector)(nil)
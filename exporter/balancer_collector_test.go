@@ -0,0 +1,63 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBalancerMetrics(t *testing.T) {
+	labels := map[string]string{labelClusterRole: "mongos"}
+
+	testCases := []struct {
+		settings bson.M
+		want     string
+	}{
+		{settings: nil, want: `mongodb_balancer_enabled{cl_role="mongos"} 1`},
+		{settings: bson.M{"stopped": false, "mode": "full"}, want: `mongodb_balancer_enabled{cl_role="mongos"} 1`},
+		{settings: bson.M{"stopped": true}, want: `mongodb_balancer_enabled{cl_role="mongos"} 0`},
+		{settings: bson.M{"mode": "off"}, want: `mongodb_balancer_enabled{cl_role="mongos"} 0`},
+	}
+
+	for _, tc := range testCases {
+		got := helpers.Format([]prometheus.Metric{balancerEnabledMetric(tc.settings, labels)})
+		assert.Equal(t, tc.want, got[2])
+	}
+
+	got := helpers.Format([]prometheus.Metric{balancerRunningMetric(bson.M{"mode": "full", "inBalancerRound": true}, labels)})
+	assert.Equal(t, `mongodb_balancer_running{cl_role="mongos"} 1`, got[2])
+
+	groups := []bson.M{
+		{"_id": "moveChunk.commit", "count": int32(12)},
+		{"_id": "moveChunk.error", "count": int32(2)},
+		{"_id": "split", "count": int32(40)},
+	}
+
+	want := []string{
+		"# HELP mongodb_balancer_migrations Number of chunk migrations currently in the config.changelog capped collection",
+		"# TYPE mongodb_balancer_migrations gauge",
+		`mongodb_balancer_migrations{cl_role="mongos",result="failed"} 2`,
+		`mongodb_balancer_migrations{cl_role="mongos",result="success"} 12`,
+	}
+
+	assert.Equal(t, want, helpers.Format(balancerMigrationsMetrics(groups, labels)))
+}
@@ -0,0 +1,93 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// checkpointCollector exposes the WiredTiger checkpoint stats from serverStatus().wiredTiger.transaction.
+// Long checkpoints are a common cause of write stalls.
+type checkpointCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *checkpointCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *checkpointCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *checkpointCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range checkpointMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// checkpointMetrics returns no metrics without the wiredTiger section, like in mongos or with
+// other storage engines. The WiredTiger stat names have spaces, each one is a single path element.
+func checkpointMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"wiredTiger", "transaction", "transaction checkpoint most recent time (msecs)"},
+			name: "mongodb_wiredtiger_checkpoint_duration_ms",
+			help: "Duration of the most recent checkpoint, in milliseconds",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"wiredTiger", "transaction", "transaction checkpoint total time (msecs)"},
+			name: "mongodb_wiredtiger_checkpoint_duration_ms_total",
+			help: "Total time spent in checkpoints, in milliseconds",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"wiredTiger", "transaction", "transaction checkpoints"},
+			name: "mongodb_wiredtiger_checkpoint_total",
+			help: "Number of checkpoints",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"wiredTiger", "transaction", "transaction checkpoint currently running"},
+			name: "mongodb_wiredtiger_checkpoint_running",
+			help: "Whether a checkpoint is running",
+			vt:   prometheus.GaugeValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*checkpointCollector)(nil)
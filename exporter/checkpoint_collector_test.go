@@ -0,0 +1,60 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCheckpointMetrics(t *testing.T) {
+	m := bson.M{
+		"wiredTiger": bson.M{
+			"transaction": bson.M{
+				"transaction checkpoint currently running":        int32(0),
+				"transaction checkpoint max time (msecs)":         int32(210),
+				"transaction checkpoint most recent time (msecs)": int32(35),
+				"transaction checkpoint total time (msecs)":       int64(4520),
+				"transaction checkpoints":                         int32(96),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_wiredtiger_checkpoint_duration_ms Duration of the most recent checkpoint, in milliseconds",
+		"# TYPE mongodb_wiredtiger_checkpoint_duration_ms gauge",
+		`mongodb_wiredtiger_checkpoint_duration_ms{rs_nm="rs1"} 35`,
+		"# HELP mongodb_wiredtiger_checkpoint_duration_ms_total Total time spent in checkpoints, in milliseconds",
+		"# TYPE mongodb_wiredtiger_checkpoint_duration_ms_total counter",
+		`mongodb_wiredtiger_checkpoint_duration_ms_total{rs_nm="rs1"} 4520`,
+		"# HELP mongodb_wiredtiger_checkpoint_running Whether a checkpoint is running",
+		"# TYPE mongodb_wiredtiger_checkpoint_running gauge",
+		`mongodb_wiredtiger_checkpoint_running{rs_nm="rs1"} 0`,
+		"# HELP mongodb_wiredtiger_checkpoint_total Number of checkpoints",
+		"# TYPE mongodb_wiredtiger_checkpoint_total counter",
+		`mongodb_wiredtiger_checkpoint_total{rs_nm="rs1"} 96`,
+	}
+
+	metrics := checkpointMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// mongos has no wiredTiger section.
+	assert.Empty(t, checkpointMetrics(bson.M{"ok": float64(1)}, nil))
+}
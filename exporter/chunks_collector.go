@@ -0,0 +1,147 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// chunksCollector exposes the number of chunks per shard and sharded collection. It only works through a mongos.
+type chunksCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+	// Add the database and collection labels besides the namespace label.
+	splitNamespaceLabels bool
+}
+
+func (d *chunksCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *chunksCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *chunksCollector) Collect(ch chan<- prometheus.Metric) {
+	config := d.client.Database("config")
+
+	// Since MongoDB 5.0, chunks reference the collection by uuid instead of ns.
+	namespaces, err := shardedCollectionsByUUID(d.ctx, config)
+	if err != nil {
+		d.logger.Errorf("cannot get the sharded collections list: %s", err)
+		d.fail()
+
+		return
+	}
+
+	group := bson.D{
+		{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"shard": "$shard", "ns": "$ns", "uuid": "$uuid"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := config.Collection("chunks").Aggregate(d.ctx, mongo.Pipeline{group})
+	if err != nil {
+		d.logger.Errorf("cannot aggregate config.chunks: %s", err)
+		d.fail()
+
+		return
+	}
+
+	var groups []bson.M
+	if err := cursor.All(d.ctx, &groups); err != nil {
+		d.logger.Errorf("cannot aggregate config.chunks: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range chunksMetrics(groups, namespaces, d.splitNamespaceLabels, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// shardedCollectionsByUUID returns the namespaces of the sharded collections indexed by their uuid.
+func shardedCollectionsByUUID(ctx context.Context, config *mongo.Database) (map[string]string, error) {
+	cursor, err := config.Collection("collections").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []bson.M
+	if err := cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]string, len(collections))
+
+	for _, c := range collections {
+		ns, _ := c["_id"].(string)
+		if uuid, ok := c["uuid"].(primitive.Binary); ok {
+			namespaces[string(uuid.Data)] = ns
+		}
+	}
+
+	return namespaces, nil
+}
+
+func chunksMetrics(groups []bson.M, namespaces map[string]string, splitNamespaceLabels bool, labels map[string]string) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(groups))
+
+	for _, group := range groups {
+		id, ok := group["_id"].(bson.M)
+		if !ok {
+			continue
+		}
+
+		ns, _ := id["ns"].(string)
+		if uuid, ok := id["uuid"].(primitive.Binary); ok && ns == "" {
+			ns = namespaces[string(uuid.Data)]
+		}
+
+		count, err := asFloat64(group["count"])
+		if err != nil || count == nil {
+			continue
+		}
+
+		chunkLabels := make(map[string]string, len(labels)+4) //nolint:gomnd
+		for k, v := range labels {
+			chunkLabels[k] = v
+		}
+
+		chunkLabels["shard"], _ = id["shard"].(string)
+		setNamespaceLabels(chunkLabels, ns, splitNamespaceLabels)
+
+		d := prometheus.NewDesc("mongodb_shard_chunks", "Number of chunks per shard and sharded collection", nil, chunkLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *count))
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*chunksCollector)(nil)
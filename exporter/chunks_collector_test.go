@@ -0,0 +1,50 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChunksMetrics(t *testing.T) {
+	uuid := primitive.Binary{Subtype: 4, Data: []byte("0123456789abcdef")}
+	namespaces := map[string]string{string(uuid.Data): "db2.col2"}
+
+	groups := []bson.M{
+		// MongoDB < 5.0
+		{"_id": bson.M{"shard": "rs1", "ns": "db1.col1"}, "count": int32(10)},
+		{"_id": bson.M{"shard": "rs2", "ns": "db1.col1"}, "count": int32(8)},
+		// MongoDB >= 5.0
+		{"_id": bson.M{"shard": "rs1", "uuid": uuid}, "count": int32(3)},
+	}
+
+	want := []string{
+		"# HELP mongodb_shard_chunks Number of chunks per shard and sharded collection",
+		"# TYPE mongodb_shard_chunks gauge",
+		`mongodb_shard_chunks{cl_role="mongos",namespace="db1.col1",shard="rs1"} 10`,
+		`mongodb_shard_chunks{cl_role="mongos",namespace="db1.col1",shard="rs2"} 8`,
+		`mongodb_shard_chunks{cl_role="mongos",namespace="db2.col2",shard="rs1"} 3`,
+	}
+
+	metrics := chunksMetrics(groups, namespaces, false, map[string]string{labelClusterRole: "mongos"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
@@ -0,0 +1,201 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// clientCache keeps a client per target between scrapes, when the global connection pool is not
+// used. A client is disconnected after being idle for the idle timeout, and a new one, with new
+// topology info, is created by the next scrape of that target.
+type clientCache struct {
+	logger      *logrus.Logger
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedClient
+	// Set by close, no clients are connected after it.
+	closed bool
+}
+
+type cachedClient struct {
+	client       *mongo.Client
+	topologyInfo labelsGetter
+	// Number of scrapes using the client. The idle timer only runs without users.
+	users int
+	timer *time.Timer
+	// Set by close while the client is in use, the last user disconnects it.
+	closing bool
+}
+
+func newClientCache(opts *Opts, idleTimeout time.Duration) *clientCache {
+	return &clientCache{
+		logger:      opts.Logger,
+		idleTimeout: idleTimeout,
+		entries:     make(map[string]*cachedClient),
+	}
+}
+
+// get returns the client for the dsn, connecting with opts if there is none, and a function to call
+// when the scrape is done with the client. It fails once the cache is closed.
+// The lock is not held while connecting, so an unreachable target doesn't block the other ones.
+func (c *clientCache) get(ctx context.Context, dsn string, opts *Opts) (*mongo.Client, labelsGetter, func(), error) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+
+		return nil, nil, nil, errExporterClosed
+	}
+
+	if entry, ok := c.entries[dsn]; ok {
+		release := c.use(dsn, entry)
+		c.mu.Unlock()
+
+		return entry.client, entry.topologyInfo, release, nil
+	}
+
+	c.mu.Unlock()
+
+	client, err := connect(ctx, dsn, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ti, err := newTopologyInfo(ctx, client)
+	if err != nil {
+		c.disconnect(client)
+
+		return nil, nil, nil, err
+	}
+
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		c.disconnect(client)
+
+		return nil, nil, nil, errExporterClosed
+	}
+
+	// Another scrape of the same target might have connected in the meantime.
+	entry, ok := c.entries[dsn]
+	if !ok {
+		entry = &cachedClient{client: client, topologyInfo: ti}
+		c.entries[dsn] = entry
+	}
+
+	release := c.use(dsn, entry)
+	c.mu.Unlock()
+
+	if ok {
+		c.disconnect(client)
+	}
+
+	return entry.client, entry.topologyInfo, release, nil
+}
+
+// use stops the idle timer of the entry and returns the function to release it, which starts the
+// timer again when there are no more users. It must be called with the lock held.
+func (c *clientCache) use(dsn string, entry *cachedClient) func() {
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+
+	entry.users++
+
+	return func() {
+		c.mu.Lock()
+
+		entry.users--
+		if entry.users > 0 {
+			c.mu.Unlock()
+
+			return
+		}
+
+		if entry.closing {
+			c.mu.Unlock()
+			c.disconnect(entry.client)
+
+			return
+		}
+
+		entry.timer = time.AfterFunc(c.idleTimeout, func() { c.expire(dsn, entry) })
+		c.mu.Unlock()
+	}
+}
+
+// expire disconnects the client if it's still idle. A scrape might have got it after the timer fired.
+// The lock is not held while disconnecting, so the other targets are not blocked meanwhile.
+func (c *clientCache) expire(dsn string, entry *cachedClient) {
+	c.mu.Lock()
+
+	if c.entries[dsn] != entry || entry.users > 0 {
+		c.mu.Unlock()
+
+		return
+	}
+
+	delete(c.entries, dsn)
+	c.mu.Unlock()
+
+	c.disconnect(entry.client)
+}
+
+// close disconnects all the idle clients. The clients in use are disconnected when their scrapes
+// release them.
+func (c *clientCache) close() {
+	c.mu.Lock()
+
+	c.closed = true
+
+	idle := make([]*mongo.Client, 0, len(c.entries))
+
+	for dsn, entry := range c.entries {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+
+		if entry.users > 0 {
+			entry.closing = true
+		} else {
+			idle = append(idle, entry.client)
+		}
+
+		delete(c.entries, dsn)
+	}
+
+	c.mu.Unlock()
+
+	for _, client := range idle {
+		c.disconnect(client)
+	}
+}
+
+func (c *clientCache) disconnect(client *mongo.Client) {
+	if err := client.Disconnect(context.Background()); err != nil {
+		c.logger.Errorf("Cannot disconnect mongo client: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestClientCache(t *testing.T) {
+	ctx := context.Background()
+	// Nothing listens on this port, but mongo.Connect doesn't wait for the server.
+	dsn := "mongodb://127.0.0.1:1"
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dsn))
+	require.NoError(t, err)
+
+	opts := &Opts{Logger: logrus.New(), ServerSelectionTimeout: 10 * time.Millisecond}
+
+	c := newClientCache(opts, 20*time.Millisecond)
+	c.entries[dsn] = &cachedClient{client: client, topologyInfo: labelsGetterMock{}}
+
+	got, _, release, err := c.get(ctx, dsn, opts)
+	require.NoError(t, err)
+	assert.Equal(t, client, got)
+
+	// The client is not closed while a scrape uses it.
+	time.Sleep(40 * time.Millisecond)
+	c.mu.Lock()
+	assert.Contains(t, c.entries, dsn)
+	c.mu.Unlock()
+
+	release()
+	time.Sleep(40 * time.Millisecond)
+	c.mu.Lock()
+	assert.NotContains(t, c.entries, dsn)
+	c.mu.Unlock()
+
+	_, _, _, err = newClientCache(opts, time.Minute).get(ctx, dsn, opts)
+	assert.Error(t, err)
+
+	// No new clients are connected once the cache is closed.
+	c.close()
+	_, _, _, err = c.get(ctx, dsn, opts)
+	assert.Equal(t, errExporterClosed, err)
+	assert.Empty(t, c.entries)
+}
+
+func TestClientCacheConnectUnlocked(t *testing.T) {
+	ctx := context.Background()
+	// Nothing listens on these ports, but mongo.Connect doesn't wait for the server.
+	cached, unreachable := "mongodb://127.0.0.1:1", "mongodb://127.0.0.1:2"
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cached))
+	require.NoError(t, err)
+
+	opts := &Opts{Logger: logrus.New(), ServerSelectionTimeout: time.Second}
+
+	c := newClientCache(opts, time.Minute)
+	c.entries[cached] = &cachedClient{client: client, topologyInfo: labelsGetterMock{}}
+
+	done := make(chan error)
+	go func() {
+		_, _, _, err := c.get(ctx, unreachable, opts)
+		done <- err
+	}()
+
+	// The cached client is returned while the other target is still connecting.
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	got, _, release, err := c.get(ctx, cached, opts)
+	require.NoError(t, err)
+	assert.Equal(t, client, got)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	release()
+
+	assert.Error(t, <-done)
+	c.close()
+}
+
+func TestClientCacheCloseInUse(t *testing.T) {
+	ctx := context.Background()
+	// Nothing listens on these ports, but mongo.Connect doesn't wait for the server.
+	used, idle := "mongodb://127.0.0.1:1", "mongodb://127.0.0.1:2"
+
+	usedClient, err := mongo.Connect(ctx, options.Client().ApplyURI(used))
+	require.NoError(t, err)
+
+	idleClient, err := mongo.Connect(ctx, options.Client().ApplyURI(idle))
+	require.NoError(t, err)
+
+	opts := &Opts{Logger: logrus.New()}
+
+	c := newClientCache(opts, time.Minute)
+	c.entries[used] = &cachedClient{client: usedClient, topologyInfo: labelsGetterMock{}}
+	c.entries[idle] = &cachedClient{client: idleClient, topologyInfo: labelsGetterMock{}}
+
+	_, _, release, err := c.get(ctx, used, opts)
+	require.NoError(t, err)
+
+	c.close()
+	assert.Empty(t, c.entries)
+	assert.True(t, isDisconnected(idleClient))
+
+	// The running scrape can still use its client, until it releases it.
+	assert.False(t, isDisconnected(usedClient))
+	release()
+	assert.True(t, isDisconnected(usedClient))
+}
+
+// isDisconnected tells if the client was disconnected. Nothing listens on the ports of these tests, so
+// a connected client fails to select a server instead.
+func isDisconnected(client *mongo.Client) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	return errors.Is(client.Ping(ctx, nil), mongo.ErrClientDisconnected)
+}
@@ -0,0 +1,108 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// collectionCountCollector exposes the number of documents per collection using the collection
+// metadata (estimatedDocumentCount), which is much cheaper than running collStats.
+type collectionCountCollector struct {
+	collectFailure
+
+	ctx             context.Context
+	client          *mongo.Client
+	databases       []string
+	discoveringMode bool
+	logger          *logrus.Logger
+	topologyInfo    labelsGetter
+}
+
+func (d *collectionCountCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *collectionCountCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *collectionCountCollector) Collect(ch chan<- prometheus.Metric) {
+	databases := d.databases
+
+	if len(databases) == 0 && d.discoveringMode {
+		dbNames, err := d.client.ListDatabaseNames(d.ctx, bson.D{})
+		if err != nil {
+			d.logger.Errorf("cannot get the database names list: %s", err)
+			d.fail()
+
+			return
+		}
+
+		databases = filterSystemDatabases(dbNames)
+	}
+
+	for _, database := range databases {
+		if database == "" {
+			continue
+		}
+
+		db := d.client.Database(database)
+
+		// Views have no documents of their own and counting them runs the view pipeline.
+		collections, err := db.ListCollectionNames(d.ctx, bson.D{{Key: "type", Value: "collection"}})
+		if err != nil {
+			d.logger.Errorf("cannot list the collections in database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		for _, collection := range collections {
+			if strings.HasPrefix(collection, "system.") {
+				continue
+			}
+
+			count, err := db.Collection(collection).EstimatedDocumentCount(d.ctx)
+			if err != nil {
+				d.logger.Errorf("cannot count the documents in %s.%s: %s", database, collection, err)
+				d.fail()
+
+				continue
+			}
+
+			ch <- collectionCountMetric(database, collection, count, d.topologyInfo.baseLabels())
+		}
+	}
+}
+
+func collectionCountMetric(database, collection string, count int64, labels map[string]string) prometheus.Metric {
+	labels["db"] = database
+	labels["collection"] = collection
+
+	d := prometheus.NewDesc("mongodb_collection_count", "Estimated number of documents in the collection", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(count))
+}
+
+var _ prometheus.Collector = (*collectionCountCollector)(nil)
@@ -0,0 +1,41 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionCountMetric(t *testing.T) {
+	metrics := []prometheus.Metric{
+		collectionCountMetric("testdb", "col1", 42, map[string]string{labelReplicasetName: "rs1"}),
+		collectionCountMetric("testdb", "col.with.dots", 0, map[string]string{labelReplicasetName: "rs1"}),
+	}
+
+	want := []string{
+		"# HELP mongodb_collection_count Estimated number of documents in the collection",
+		"# TYPE mongodb_collection_count gauge",
+		`mongodb_collection_count{collection="col.with.dots",db="testdb",rs_nm="rs1"} 0`,
+		`mongodb_collection_count{collection="col1",db="testdb",rs_nm="rs1"} 42`,
+	}
+
+	assert.Equal(t, want, helpers.Format(metrics))
+}
@@ -18,8 +18,13 @@ package exporter
 
 import (
 	"context"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -27,33 +32,65 @@ import (
 )
 
 type collstatsCollector struct {
+	collectFailure
+
 	ctx             context.Context
 	client          *mongo.Client
 	collections     []string
 	compatibleMode  bool
+	remaps          *compatibleRemaps
 	discoveringMode bool
 	logger          *logrus.Logger
 	topologyInfo    labelsGetter
+	// If not nil, the $collStats results are reused until they are older than the cache TTL.
+	cache *collStatsCache
+	// If not nil, it counts the $collStats errors by namespace across the scrapes.
+	errors *collStatsErrors
+	// Collection name patterns, used only in discovering mode. maxPatternMatches caps the
+	// collections matched by all the patterns, zero means no limit.
+	patterns          []namespacePattern
+	maxPatternMatches int
+	// Maximum number of indexes per collection with a size metric, zero means no limit.
+	maxIndexes int
+	// listCollections filter for the discovered collections, from collectionTypesFilter.
+	typesFilter bson.D
+	// Add the latency counters by operation type, from latencyStats.
+	includeLatency bool
+	// In discovering mode, maximum number of collections across all the databases. Zero means no limit.
+	maxDiscovered int
+	// Discover the system.* collections too.
+	includeSystem bool
 }
 
 func (d *collstatsCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
+func (d *collstatsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
 func (d *collstatsCollector) Collect(ch chan<- prometheus.Metric) {
+	// The discovered collections are not kept in d.collections since the databases matched by the
+	// patterns would be discovered completely on the next Collect.
+	collections := d.collections
 	if d.discoveringMode {
-		databases := map[string][]string{}
-		for _, dbCollection := range d.collections {
-			parts := strings.Split(dbCollection, ".")
-			if _, ok := databases[parts[0]]; !ok {
-				db := parts[0]
-				databases[db], _ = d.client.Database(parts[0]).ListCollectionNames(d.ctx, bson.D{})
-			}
+		collections = d.discoverCollections()
+
+		if d.maxDiscovered > 0 {
+			var capped prometheus.Metric
+
+			collections, capped = capDiscoveredCollections(collections, d.maxDiscovered, "collstats",
+				d.logger, d.topologyInfo.baseLabels())
+			ch <- capped
 		}
+	}
 
-		d.collections = fromMapToSlice(databases)
+	if d.errors != nil {
+		d.errors.prune(collections)
 	}
-	for _, dbCollection := range d.collections {
+
+	for _, dbCollection := range collections {
 		parts := strings.Split(dbCollection, ".")
 		if len(parts) != 2 { //nolint:gomnd
 			continue
@@ -62,53 +99,319 @@ func (d *collstatsCollector) Collect(ch chan<- prometheus.Metric) {
 		database := parts[0]
 		collection := parts[1]
 
-		aggregation := bson.D{
-			{
-				Key: "$collStats", Value: bson.M{
-					"latencyStats": bson.E{Key: "histograms", Value: true},
-					"storageStats": bson.E{Key: "scale", Value: 1},
-				},
-			},
-		}
-		project := bson.D{
-			{
-				Key: "$project", Value: bson.M{
-					"storageStats.wiredTiger":   0,
-					"storageStats.indexDetails": 0,
-				},
-			},
-		}
+		labels := d.topologyInfo.baseLabels()
+		labels["database"] = database
+		labels["collection"] = collection
 
-		cursor, err := d.client.Database(database).Collection(collection).Aggregate(d.ctx, mongo.Pipeline{aggregation, project})
+		// The collection might have been dropped after the discovery, the other ones are still collected.
+		stats, err := d.cachedCollStats(database, collection, labels, ch)
 		if err != nil {
-			d.logger.Errorf("cannot get $collstats cursor for collection %s.%s: %s", database, collection, err)
-			continue
-		}
+			d.logger.Debugf("cannot get $collstats for collection %s.%s: %s", database, collection, err)
+
+			if d.errors != nil {
+				d.errors.inc(database + "." + collection)
+			}
 
-		var stats []bson.M
-		if err = cursor.All(d.ctx, &stats); err != nil {
-			d.logger.Errorf("cannot get $collstats for collection %s.%s: %s", database, collection, err)
 			continue
 		}
 
-		d.logger.Debugf("$collStats metrics for %s.%s", database, collection)
-		debugResult(d.logger, stats)
-
 		// Since all collections will have the same fields, we need to use a metric prefix (db+col)
 		// to differentiate metrics between collection. Labels are being set only to matke it easier
 		// to filter
 		prefix := database + "." + collection
 
-		labels := d.topologyInfo.baseLabels()
-		labels["database"] = database
-		labels["collection"] = collection
-
 		for _, metrics := range stats {
-			for _, metric := range makeMetrics(prefix, metrics, labels, d.compatibleMode) {
+			for _, metric := range makeMetrics(prefix, metrics, labels, d.compatibleMode, d.remaps) {
 				ch <- metric
 			}
+
+			sizes, skipped := indexSizeMetrics(metrics, prefix, d.maxIndexes, labels)
+			if skipped > 0 {
+				d.logger.Debugf("skipping the size of %d indexes of %s, over the limit of %d", skipped, prefix, d.maxIndexes)
+			}
+
+			for _, metric := range sizes {
+				ch <- metric
+			}
+
+			if d.includeLatency {
+				for _, metric := range latencyMetrics(metrics, prefix, labels) {
+					ch <- metric
+				}
+			}
+		}
+	}
+
+	if d.errors != nil {
+		for _, metric := range d.errors.metrics(d.topologyInfo.baseLabels()) {
+			ch <- metric
+		}
+	}
+}
+
+// indexSizeMetrics returns a gauge per index from storageStats.indexSizes, sorted by index name so
+// the same indexes are kept on every scrape, up to max indexes if max is not zero, and the number
+// of indexes skipped.
+func indexSizeMetrics(stats bson.M, ns string, max int, labels map[string]string) ([]prometheus.Metric, int) {
+	sizes, ok := walkTo(stats, []string{"storageStats", "indexSizes"}).(bson.M)
+	if !ok {
+		return nil, 0
+	}
+
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	skipped := 0
+	if max > 0 && len(names) > max {
+		skipped = len(names) - max
+		names = names[:max]
+	}
+
+	metrics := make([]prometheus.Metric, 0, len(names))
+
+	for _, name := range names {
+		f, err := asFloat64(sizes[name])
+		if err != nil || f == nil {
+			continue
+		}
+
+		indexLabels := collStatsLabels(stats, ns, labels)
+		indexLabels["index"] = name
+
+		d := prometheus.NewDesc("mongodb_collstats_index_size_bytes", "Size of the index", nil, indexLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *f))
+	}
+
+	return metrics, skipped
+}
+
+// latencyMetrics returns the number of operations and their total latency by type from latencyStats.
+// The collections without latencyStats, like views, have no metrics.
+func latencyMetrics(stats bson.M, ns string, labels map[string]string) []prometheus.Metric {
+	latencyStats, ok := stats["latencyStats"].(bson.M)
+	if !ok {
+		return nil
+	}
+
+	defs := make([]fieldMetric, 0, 2*len(latencyStats)) //nolint:gomnd
+
+	for typ := range latencyStats {
+		defs = append(defs,
+			fieldMetric{
+				path:   []string{typ, "ops"},
+				name:   "mongodb_collstats_latency_ops_total",
+				help:   "Number of operations on the collection, by type",
+				vt:     prometheus.CounterValue,
+				labels: map[string]string{"type": typ},
+			},
+			fieldMetric{
+				path:   []string{typ, "latency"},
+				name:   "mongodb_collstats_latency_micros_total",
+				help:   "Total latency of the operations on the collection, by type, in microseconds",
+				vt:     prometheus.CounterValue,
+				labels: map[string]string{"type": typ},
+			},
+		)
+	}
+
+	return fieldMetrics(latencyStats, defs, collStatsLabels(stats, ns, labels))
+}
+
+// collStatsLabels returns a copy of the labels with the namespace of the $collStats result and,
+// through a mongos, where there is a result per shard, the shard.
+func collStatsLabels(stats bson.M, ns string, labels map[string]string) map[string]string {
+	res := make(map[string]string, len(labels)+3) //nolint:gomnd
+	for k, v := range labels {
+		res[k] = v
+	}
+
+	res["namespace"] = ns
+
+	if shard, ok := stats["shard"].(string); ok {
+		res["shard"] = shard
+	}
+
+	return res
+}
+
+// discoverCollections returns all the collections of the databases in the collections list plus
+// the collections matching the patterns. Since the databases in the list are discovered completely,
+// the patterns only make a difference for other databases.
+func (d *collstatsCollector) discoverCollections() []string {
+	databases := map[string][]string{}
+	for _, dbCollection := range d.collections {
+		parts := strings.Split(dbCollection, ".")
+		if _, ok := databases[parts[0]]; !ok {
+			db := parts[0]
+			names, _ := d.client.Database(parts[0]).ListCollectionNames(d.ctx, d.listFilter())
+			databases[db] = filterSystemCollections(names, d.includeSystem)
 		}
 	}
+
+	collections := fromMapToSlice(databases)
+
+	if len(d.patterns) == 0 {
+		return collections
+	}
+
+	listed := map[string][]string{}
+	for _, p := range d.patterns {
+		if _, ok := databases[p.database]; ok {
+			continue
+		}
+
+		if _, ok := listed[p.database]; ok {
+			continue
+		}
+
+		names, err := d.client.Database(p.database).ListCollectionNames(d.ctx, d.listFilter())
+		if err != nil {
+			d.logger.Errorf("cannot list the collections of %s: %s", p.database, err)
+			d.fail()
+			continue
+		}
+
+		listed[p.database] = filterSystemCollections(names, d.includeSystem)
+	}
+
+	matched, truncated := matchNamespacePatterns(d.patterns, listed, d.maxPatternMatches)
+	if truncated {
+		d.logger.Warnf("more than %d collections match the collstats patterns, only the first %d are used",
+			d.maxPatternMatches, d.maxPatternMatches)
+	}
+
+	return append(collections, matched...)
+}
+
+func (d *collstatsCollector) listFilter() bson.D {
+	if d.typesFilter == nil {
+		return bson.D{}
+	}
+
+	return d.typesFilter
+}
+
+// collectionTypesFilter returns the listCollections filter to discover only the collections of the
+// given types: the listCollections types, like collection, view or timeseries, and capped or
+// clustered for the collections with those options. Without types, it returns nil.
+func collectionTypesFilter(types []string) (bson.D, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var (
+		listTypes  []string
+		conditions bson.A
+	)
+
+	for _, t := range types {
+		switch t {
+		case "collection", "view", "timeseries":
+			listTypes = append(listTypes, t)
+		case "capped":
+			conditions = append(conditions, bson.D{{Key: "options.capped", Value: true}})
+		case "clustered":
+			conditions = append(conditions, bson.D{{Key: "options.clusteredIndex", Value: bson.D{{Key: "$exists", Value: true}}}})
+		default:
+			return nil, errors.Errorf("invalid collection type %q, valid types: collection, view, timeseries, capped, clustered", t)
+		}
+	}
+
+	if len(listTypes) > 0 {
+		conditions = append(conditions, bson.D{{Key: "type", Value: bson.D{{Key: "$in", Value: listTypes}}}})
+	}
+
+	return bson.D{{Key: "$or", Value: conditions}}, nil
+}
+
+// cachedCollStats returns the $collStats results from the cache if they are fresh enough and
+// sends the age of the results. Without a cache, it just runs $collStats.
+func (d *collstatsCollector) cachedCollStats(database, collection string, labels map[string]string, ch chan<- prometheus.Metric) ([]bson.M, error) {
+	if d.cache == nil {
+		return d.collStats(database, collection)
+	}
+
+	ns := database + "." + collection
+	now := time.Now()
+
+	stats, updated, ok := d.cache.get(ns, now)
+	if !ok {
+		var err error
+		if stats, err = d.collStats(database, collection); err != nil {
+			return nil, err
+		}
+
+		updated = now
+		d.cache.set(ns, stats, updated)
+	}
+
+	ch <- collStatsCacheAgeMetric(now.Sub(updated), labels)
+
+	return stats, nil
+}
+
+func (d *collstatsCollector) collStats(database, collection string) ([]bson.M, error) {
+	aggregation := bson.D{
+		{
+			Key: "$collStats", Value: bson.M{
+				"latencyStats": bson.E{Key: "histograms", Value: true},
+				"storageStats": bson.E{Key: "scale", Value: 1},
+			},
+		},
+	}
+	project := bson.D{
+		{
+			Key: "$project", Value: bson.M{
+				"storageStats.wiredTiger":   0,
+				"storageStats.indexDetails": 0,
+			},
+		},
+	}
+
+	cursor, err := d.client.Database(database).Collection(collection).Aggregate(d.ctx, mongo.Pipeline{aggregation, project})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []bson.M
+	if err = cursor.All(d.ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	d.logger.Debugf("$collStats metrics for %s.%s", database, collection)
+	debugResult(d.logger, stats)
+
+	return stats, nil
+}
+
+// filterSystemCollections removes the system.* collections from the discovered collections unless
+// includeSystem is set. Even then, the legacy system.indexes and system.namespaces are removed since
+// they cannot be aggregated, and the unsupported ones, only system.views for $indexStats.
+func filterSystemCollections(names []string, includeSystem bool, unsupported ...string) []string {
+	skip := map[string]bool{"system.indexes": true, "system.namespaces": true}
+	for _, name := range unsupported {
+		skip[name] = true
+	}
+
+	filtered := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "system.") {
+			filtered = append(filtered, name)
+
+			continue
+		}
+
+		if includeSystem && !skip[name] {
+			filtered = append(filtered, name)
+		}
+	}
+
+	return filtered
 }
 
 func fromMapToSlice(databases map[string][]string) []string {
@@ -122,4 +425,187 @@ func fromMapToSlice(databases map[string][]string) []string {
 	return collections
 }
 
+// namespacePattern matches the collection names of a database.
+type namespacePattern struct {
+	database string
+	re       *regexp.Regexp
+}
+
+// parseCollStatsCollections splits the collections list in db.collection names and db/regex
+// patterns. The patterns must match the whole collection name.
+func parseCollStatsCollections(entries []string) ([]string, []namespacePattern, error) {
+	var (
+		collections []string
+		patterns    []namespacePattern
+	)
+
+	for _, entry := range entries {
+		i := strings.Index(entry, "/")
+		if i < 0 {
+			collections = append(collections, entry)
+			continue
+		}
+
+		re, err := regexp.Compile("^(?:" + entry[i+1:] + ")$")
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid collection pattern %q", entry)
+		}
+
+		patterns = append(patterns, namespacePattern{database: entry[:i], re: re})
+	}
+
+	return collections, patterns, nil
+}
+
+// matchNamespacePatterns returns the namespaces of the listed collections matching the patterns,
+// sorted by name so the same collections are used on every scrape, up to max namespaces if
+// max is not zero, and whether the list was truncated.
+func matchNamespacePatterns(patterns []namespacePattern, listed map[string][]string, max int) ([]string, bool) {
+	seen := map[string]bool{}
+
+	var namespaces []string
+
+	for _, p := range patterns {
+		for _, name := range listed[p.database] {
+			ns := p.database + "." + name
+			if seen[ns] || !p.re.MatchString(name) {
+				continue
+			}
+
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	sort.Strings(namespaces)
+
+	if max > 0 && len(namespaces) > max {
+		return namespaces[:max], true
+	}
+
+	return namespaces, false
+}
+
+func collStatsCacheAgeMetric(age time.Duration, labels map[string]string) prometheus.Metric {
+	d := prometheus.NewDesc("mongodb_collstats_cache_age_seconds", "Age of the cached $collStats results", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, age.Seconds())
+}
+
+// collStatsCache keeps the $collStats results per namespace. It's shared by all the collstats
+// collectors since a new collector is created on each scrape.
+type collStatsCache struct {
+	ttl     time.Duration
+	rw      sync.RWMutex
+	entries map[string]collStatsCacheEntry
+	// When the expired entries were last removed, at most once per TTL.
+	pruned time.Time
+}
+
+type collStatsCacheEntry struct {
+	stats   []bson.M
+	updated time.Time
+}
+
+func newCollStatsCache(ttl time.Duration) *collStatsCache {
+	return &collStatsCache{
+		ttl:     ttl,
+		entries: make(map[string]collStatsCacheEntry),
+	}
+}
+
+// get returns the cached results for the namespace and when they were updated, if they are not older than the TTL.
+func (c *collStatsCache) get(ns string, now time.Time) ([]bson.M, time.Time, bool) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	entry, ok := c.entries[ns]
+	if !ok || now.Sub(entry.updated) >= c.ttl {
+		return nil, time.Time{}, false
+	}
+
+	return entry.stats, entry.updated, true
+}
+
+// set keeps the results for the namespace and removes the expired entries, like the ones of the
+// dropped collections, so the cache doesn't grow forever with rotating collections.
+func (c *collStatsCache) set(ns string, stats []bson.M, updated time.Time) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+
+	c.entries[ns] = collStatsCacheEntry{stats: stats, updated: updated}
+
+	if updated.Sub(c.pruned) < c.ttl {
+		return
+	}
+
+	for ns, entry := range c.entries {
+		if updated.Sub(entry.updated) >= c.ttl {
+			delete(c.entries, ns)
+		}
+	}
+
+	c.pruned = updated
+}
+
+// collStatsErrors counts the $collStats errors per namespace. Like the cache, it's shared by all
+// the collstats collectors of the exporter, since they only live for a scrape.
+type collStatsErrors struct {
+	m      sync.Mutex
+	counts map[string]float64
+}
+
+func newCollStatsErrors() *collStatsErrors {
+	return &collStatsErrors{counts: make(map[string]float64)}
+}
+
+func (c *collStatsErrors) inc(ns string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.counts[ns]++
+}
+
+// prune removes the counts of the namespaces not in the list, like the dropped collections, so
+// their series are not exported forever.
+func (c *collStatsErrors) prune(namespaces []string) {
+	keep := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		keep[ns] = true
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for ns := range c.counts {
+		if !keep[ns] {
+			delete(c.counts, ns)
+		}
+	}
+}
+
+// metrics returns a counter for each namespace with errors. There are no series for the namespaces
+// without errors.
+func (c *collStatsErrors) metrics(labels map[string]string) []prometheus.Metric {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	metrics := make([]prometheus.Metric, 0, len(c.counts))
+
+	for ns, count := range c.counts {
+		nsLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			nsLabels[k] = v
+		}
+
+		nsLabels["namespace"] = ns
+
+		d := prometheus.NewDesc("mongodb_collstats_errors_total", "Number of $collStats errors of the collection",
+			nil, nsLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.CounterValue, count))
+	}
+
+	return metrics
+}
+
 var _ prometheus.Collector = (*collstatsCollector)(nil)
@@ -23,10 +23,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/percona/mongodb_exporter/internal/tu"
 )
@@ -85,3 +91,280 @@ mongodb_testdb_testcol_02_latencyStats_commands_latency{collection="testcol_02",
 	err := testutil.CollectAndCompare(c, expected, filter...)
 	assert.NoError(t, err)
 }
+
+func TestCollStatsCollectorPatterns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	client := tu.DefaultTestClient(ctx, t)
+
+	database := client.Database("testdb_patterns")
+	database.Drop(ctx) //nolint
+
+	defer func() {
+		err := database.Drop(ctx)
+		assert.NoError(t, err)
+	}()
+
+	for _, coll := range []string{"events_01", "events_02", "users"} {
+		_, err := database.Collection(coll).InsertOne(ctx, bson.M{"f1": 1})
+		require.NoError(t, err)
+	}
+
+	_, patterns, err := parseCollStatsCollections([]string{"testdb_patterns/events_.*"})
+	require.NoError(t, err)
+
+	c := &collstatsCollector{
+		ctx:             ctx,
+		client:          client,
+		discoveringMode: true,
+		patterns:        patterns,
+		logger:          logrus.New(),
+		topologyInfo:    labelsGetterMock{},
+	}
+
+	// Describe collects the metrics once, the next Collect must still use the patterns.
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+
+	for range descs {
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(metrics)
+		close(metrics)
+	}()
+
+	collections := map[string]bool{}
+
+	for m := range metrics {
+		var pb dto.Metric
+		require.NoError(t, m.Write(&pb))
+
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "collection" {
+				collections[l.GetValue()] = true
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]bool{"events_01": true, "events_02": true}, collections)
+}
+
+func TestCollStatsCache(t *testing.T) {
+	cache := newCollStatsCache(time.Minute)
+	now := time.Now()
+
+	_, _, ok := cache.get("db.col", now)
+	assert.False(t, ok)
+
+	stats := []bson.M{{"ns": "db.col", "count": int32(10)}}
+	cache.set("db.col", stats, now)
+
+	got, updated, ok := cache.get("db.col", now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, stats, got)
+	assert.Equal(t, now, updated)
+
+	_, _, ok = cache.get("db.col", now.Add(time.Minute))
+	assert.False(t, ok)
+
+	// The expired entries, like the ones of the dropped collections, are removed.
+	cache.set("db.events_2024_01", stats, now.Add(30*time.Second))
+	cache.set("db.events_2024_02", stats, now.Add(2*time.Minute))
+	assert.Len(t, cache.entries, 1)
+	assert.Contains(t, cache.entries, "db.events_2024_02")
+}
+
+func TestParseCollStatsCollections(t *testing.T) {
+	collections, patterns, err := parseCollStatsCollections([]string{"db1.c1", "db2/events_.*", "db2/logs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1.c1"}, collections)
+	require.Len(t, patterns, 2)
+	assert.Equal(t, "db2", patterns[0].database)
+	assert.True(t, patterns[0].re.MatchString("events_2024_01"))
+	// Patterns match the whole collection name.
+	assert.False(t, patterns[0].re.MatchString("old_events_2024_01"))
+	assert.True(t, patterns[1].re.MatchString("logs"))
+	assert.False(t, patterns[1].re.MatchString("logs_old"))
+
+	_, _, err = parseCollStatsCollections([]string{"db1/events_("})
+	assert.Error(t, err)
+}
+
+func TestMatchNamespacePatterns(t *testing.T) {
+	_, patterns, err := parseCollStatsCollections([]string{"db1/events_.*", "db1/events_2024_.*", "db2/logs"})
+	require.NoError(t, err)
+
+	listed := map[string][]string{
+		"db1": {"events_2024_02", "users", "events_2024_01"},
+		"db2": {"logs", "logs_old"},
+	}
+
+	matched, truncated := matchNamespacePatterns(patterns, listed, 0)
+	assert.Equal(t, []string{"db1.events_2024_01", "db1.events_2024_02", "db2.logs"}, matched)
+	assert.False(t, truncated)
+
+	matched, truncated = matchNamespacePatterns(patterns, listed, 2)
+	assert.Equal(t, []string{"db1.events_2024_01", "db1.events_2024_02"}, matched)
+	assert.True(t, truncated)
+}
+
+func TestIndexSizeMetrics(t *testing.T) {
+	stats := bson.M{
+		"storageStats": bson.M{
+			"size": int32(1000),
+			"indexSizes": bson.M{
+				"_id_":  int32(36864),
+				"f1_1":  int32(20480),
+				"f2_-1": int64(4096),
+			},
+		},
+	}
+	labels := map[string]string{labelReplicasetName: "rs1"}
+
+	metrics, skipped := indexSizeMetrics(stats, "testdb.testcol", 2, labels)
+	assert.Equal(t, 1, skipped)
+
+	want := []string{
+		"# HELP mongodb_collstats_index_size_bytes Size of the index",
+		"# TYPE mongodb_collstats_index_size_bytes gauge",
+		`mongodb_collstats_index_size_bytes{index="_id_",namespace="testdb.testcol",rs_nm="rs1"} 36864`,
+		`mongodb_collstats_index_size_bytes{index="f1_1",namespace="testdb.testcol",rs_nm="rs1"} 20480`,
+	}
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	metrics, skipped = indexSizeMetrics(stats, "testdb.testcol", 0, labels)
+	assert.Equal(t, 0, skipped)
+	assert.Len(t, metrics, 3)
+
+	metrics, _ = indexSizeMetrics(bson.M{"storageStats": bson.M{"size": int32(1000)}}, "testdb.testcol", 0, labels)
+	assert.Empty(t, metrics)
+}
+
+func TestCollectionTypesFilter(t *testing.T) {
+	filter, err := collectionTypesFilter(nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = collectionTypesFilter([]string{"capped", "timeseries"})
+	require.NoError(t, err)
+	want := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "options.capped", Value: true}},
+		bson.D{{Key: "type", Value: bson.D{{Key: "$in", Value: []string{"timeseries"}}}}},
+	}}}
+	assert.Equal(t, want, filter)
+
+	_, err = collectionTypesFilter([]string{"sharded"})
+	assert.Error(t, err)
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	stats := bson.M{
+		"shard": "rs1",
+		"latencyStats": bson.M{
+			"reads":    bson.M{"latency": int64(1200), "ops": int64(10), "histogram": bson.A{}},
+			"writes":   bson.M{"latency": int64(500), "ops": int64(2), "histogram": bson.A{}},
+			"commands": bson.M{"latency": int64(0), "ops": int64(0), "histogram": bson.A{}},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_collstats_latency_micros_total Total latency of the operations on the collection, by type, in microseconds",
+		"# TYPE mongodb_collstats_latency_micros_total counter",
+		`mongodb_collstats_latency_micros_total{namespace="testdb.testcol",shard="rs1",type="commands"} 0`,
+		`mongodb_collstats_latency_micros_total{namespace="testdb.testcol",shard="rs1",type="reads"} 1200`,
+		`mongodb_collstats_latency_micros_total{namespace="testdb.testcol",shard="rs1",type="writes"} 500`,
+		"# HELP mongodb_collstats_latency_ops_total Number of operations on the collection, by type",
+		"# TYPE mongodb_collstats_latency_ops_total counter",
+		`mongodb_collstats_latency_ops_total{namespace="testdb.testcol",shard="rs1",type="commands"} 0`,
+		`mongodb_collstats_latency_ops_total{namespace="testdb.testcol",shard="rs1",type="reads"} 10`,
+		`mongodb_collstats_latency_ops_total{namespace="testdb.testcol",shard="rs1",type="writes"} 2`,
+	}
+
+	assert.Equal(t, want, helpers.Format(latencyMetrics(stats, "testdb.testcol", nil)))
+
+	// Views have no latencyStats.
+	assert.Empty(t, latencyMetrics(bson.M{"ns": "testdb.view"}, "testdb.view", nil))
+}
+
+func TestCollStatsErrors(t *testing.T) {
+	errs := newCollStatsErrors()
+	assert.Empty(t, errs.metrics(nil))
+
+	errs.inc("testdb.dropped")
+	errs.inc("testdb.dropped")
+	errs.inc("testdb.other")
+
+	want := []string{
+		"# HELP mongodb_collstats_errors_total Number of $collStats errors of the collection",
+		"# TYPE mongodb_collstats_errors_total counter",
+		`mongodb_collstats_errors_total{namespace="testdb.dropped",rs_nm="rs1"} 2`,
+		`mongodb_collstats_errors_total{namespace="testdb.other",rs_nm="rs1"} 1`,
+	}
+
+	metrics := errs.metrics(map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// The dropped collection is not discovered anymore.
+	errs.prune([]string{"testdb.other", "testdb.new"})
+
+	want = []string{
+		"# HELP mongodb_collstats_errors_total Number of $collStats errors of the collection",
+		"# TYPE mongodb_collstats_errors_total counter",
+		`mongodb_collstats_errors_total{namespace="testdb.other",rs_nm="rs1"} 1`,
+	}
+
+	metrics = errs.metrics(map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
+
+func TestCollStatsErrorsRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing listens on this port, so $collStats fails.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	defer client.Disconnect(ctx) //nolint:errcheck
+
+	e := &Exporter{logger: logrus.New(), opts: &Opts{}}
+	errs := newCollStatsErrors()
+
+	c := &collstatsCollector{
+		ctx:          ctx,
+		client:       client,
+		collections:  []string{"testdb.testcol"},
+		logger:       e.logger,
+		topologyInfo: labelsGetterMock{},
+		errors:       errs,
+	}
+
+	// Like makeRegistry.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e.instrument(ctx, "collstats", c))
+
+	_, err = registry.Gather()
+	require.NoError(t, err)
+
+	// A single error for a single scrape.
+	want := []string{
+		"# HELP mongodb_collstats_errors_total Number of $collStats errors of the collection",
+		"# TYPE mongodb_collstats_errors_total counter",
+		`mongodb_collstats_errors_total{namespace="testdb.testcol"} 1`,
+	}
+	assert.Equal(t, want, helpers.Format(errs.metrics(nil)))
+}
+
+func TestFilterSystemCollections(t *testing.T) {
+	names := []string{"users", "system.profile", "system.views", "system.indexes", "system.namespaces", "orders"}
+
+	assert.Equal(t, []string{"users", "orders"}, filterSystemCollections(names, false))
+	assert.Equal(t, []string{"users", "system.profile", "system.views", "orders"}, filterSystemCollections(names, true))
+	assert.Equal(t, []string{"users", "system.profile", "orders"}, filterSystemCollections(names, true, "system.views"))
+}
@@ -0,0 +1,175 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// configServerCollector exposes the state of the config server replica set members. It only works
+// through a mongos: the config servers are found with getShardMap and connected with the
+// credentials and the options of the URI. The connections are kept in the clients cache.
+type configServerCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	opts         *Opts
+	clients      *clientCache
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *configServerCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *configServerCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *configServerCollector) Collect(ch chan<- prometheus.Metric) {
+	var shardMap bson.M
+
+	cmd := bson.D{{Key: "getShardMap", Value: 1}}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&shardMap); err != nil {
+		d.logger.Errorf("cannot get getShardMap: %s", err)
+		d.fail()
+
+		return
+	}
+
+	dsn, err := configServerTarget(shardMap, d.opts.URI)
+	if err != nil {
+		d.logger.Errorf("cannot get the config servers: %s", err)
+		d.fail()
+
+		return
+	}
+
+	// The config servers are a replica set, a direct connection would only reach one member.
+	opts := *d.opts
+	opts.DirectConnect = false
+
+	client, _, release, err := d.clients.get(d.ctx, dsn, &opts)
+	if err != nil {
+		d.logger.Errorf("cannot connect to the config servers: %s", err)
+		d.fail()
+
+		return
+	}
+
+	defer release()
+
+	var status bson.M
+
+	cmd = bson.D{{Key: "replSetGetStatus", Value: 1}}
+	if err := client.Database("admin").RunCommand(d.ctx, cmd).Decode(&status); err != nil {
+		d.logger.Errorf("cannot get replSetGetStatus from the config servers: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range configServerMemberMetrics(status, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// configServerTarget returns the URI of the config server replica set from the getShardMap
+// result. The config entry has the form <replica set>/<host1>,<host2>. Newer versions also have the
+// connStrings section with the same format, used if the map has no config entry.
+// The URI is the given one with the config server hosts and replica set, so the credentials and
+// the authentication and TLS options are the same.
+func configServerTarget(shardMap bson.M, uri string) (string, error) {
+	var conn string
+
+	for _, section := range []string{"map", "connStrings"} {
+		if m, ok := shardMap[section].(bson.M); ok {
+			if conn, _ = m["config"].(string); conn != "" {
+				break
+			}
+		}
+	}
+
+	parts := strings.SplitN(conn, "/", 2) //nolint:gomnd
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.Errorf("unexpected config servers connection string %q", conn)
+	}
+
+	target, err := uriWithHosts(uri, "mongodb", parts[1], url.Values{"replicaSet": {parts[0]}})
+	if err != nil {
+		return "", errors.Wrap(err, "invalid URI")
+	}
+
+	return target, nil
+}
+
+func configServerMemberMetrics(status bson.M, labels map[string]string) []prometheus.Metric {
+	members, ok := status["members"].(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	metrics := make([]prometheus.Metric, 0, 2*len(members)) //nolint:gomnd
+
+	for _, member := range members {
+		mm, ok := member.(bson.M)
+		if !ok {
+			continue
+		}
+
+		memberLabels := make(map[string]string, len(labels)+3) //nolint:gomnd
+		for k, v := range labels {
+			memberLabels[k] = v
+		}
+
+		memberLabels["set"], _ = status["set"].(string)
+		memberLabels["name"], _ = mm["name"].(string)
+		memberLabels["state"], _ = mm["stateStr"].(string)
+
+		defs := []fieldMetric{
+			{
+				path: []string{"state"},
+				name: "mongodb_config_server_member_state",
+				help: "State of the config server replica set member, as the replSetGetStatus state number",
+				vt:   prometheus.GaugeValue,
+			},
+			{
+				path: []string{"health"},
+				name: "mongodb_config_server_member_health",
+				help: "Whether the config server replica set member is up (1) or down (0)",
+				vt:   prometheus.GaugeValue,
+			},
+		}
+
+		metrics = append(metrics, fieldMetrics(mm, defs, memberLabels)...)
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*configServerCollector)(nil)
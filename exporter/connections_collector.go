@@ -0,0 +1,91 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// connectionsCollector exposes serverStatus().connections with stable metric names,
+// independently of the compatible mode.
+type connectionsCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *connectionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *connectionsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *connectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range connectionsMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+func connectionsMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"connections", "current"},
+			name: "mongodb_connections_current",
+			help: "The number of incoming connections from clients to the database server",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"connections", "available"},
+			name: "mongodb_connections_available",
+			help: "The number of unused incoming connections available",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"connections", "active"},
+			name: "mongodb_connections_active",
+			help: "The number of active client connections to the server",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"connections", "totalCreated"},
+			name: "mongodb_connections_created_total",
+			help: "Count of all incoming connections created to the server",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*connectionsCollector)(nil)
@@ -0,0 +1,53 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestConnectionsMetrics(t *testing.T) {
+	m := bson.M{
+		"connections": bson.M{
+			"current":      int32(12),
+			"available":    int32(838848),
+			"totalCreated": int32(170),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_connections_available The number of unused incoming connections available",
+		"# TYPE mongodb_connections_available gauge",
+		`mongodb_connections_available{rs_nm="rs1"} 838848`,
+		"# HELP mongodb_connections_created_total Count of all incoming connections created to the server",
+		"# TYPE mongodb_connections_created_total counter",
+		`mongodb_connections_created_total{rs_nm="rs1"} 170`,
+		"# HELP mongodb_connections_current The number of incoming connections from clients to the database server",
+		"# TYPE mongodb_connections_current gauge",
+		`mongodb_connections_current{rs_nm="rs1"} 12`,
+	}
+
+	metrics := connectionsMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// The connections section missing is not an error.
+	assert.Empty(t, connectionsMetrics(bson.M{}, nil))
+}
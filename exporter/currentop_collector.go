@@ -0,0 +1,142 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultCurrentOpSlowThreshold = time.Minute
+
+type currentopCollector struct {
+	collectFailure
+
+	ctx           context.Context
+	client        *mongo.Client
+	slowThreshold time.Duration
+	logger        *logrus.Logger
+	topologyInfo  labelsGetter
+}
+
+func (d *currentopCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *currentopCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *currentopCollector) Collect(ch chan<- prometheus.Metric) {
+	var m bson.M
+
+	cmd := bson.D{{Key: "currentOp", Value: 1}, {Key: "active", Value: true}}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&m); err != nil {
+		d.logger.Errorf("cannot run currentOp: %s", err)
+		d.fail()
+
+		return
+	}
+
+	inprog, ok := m["inprog"].(primitive.A)
+	if !ok {
+		d.logger.Errorf("cannot decode currentOp: %T for inprog field", m["inprog"])
+		d.fail()
+
+		return
+	}
+
+	threshold := d.slowThreshold
+	if threshold <= 0 {
+		threshold = defaultCurrentOpSlowThreshold
+	}
+
+	for _, metric := range currentOpMetrics(inprog, threshold, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// currentOpMetrics returns a metric for each operation running for longer than threshold, to keep
+// the cardinality low, and the total count of those operations.
+func currentOpMetrics(inprog primitive.A, threshold time.Duration, labels map[string]string) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	slowCount := 0
+
+	for _, item := range inprog {
+		op, ok := item.(bson.M)
+		if !ok {
+			continue
+		}
+
+		running, err := opRunningTime(op)
+		if err != nil || running <= threshold {
+			continue
+		}
+
+		slowCount++
+
+		opLabels := make(map[string]string, len(labels)+3) //nolint:gomnd
+		for k, v := range labels {
+			opLabels[k] = v
+		}
+
+		// Some operations, like the internal ones, have no namespace.
+		opLabels["op"], _ = op["op"].(string)
+		opLabels["ns"], _ = op["ns"].(string)
+		opLabels["opid"] = fmt.Sprintf("%v", op["opid"])
+
+		d := prometheus.NewDesc("mongodb_currentop_query_uptime_seconds",
+			"The time an operation running for longer than the slow threshold has been running", nil, opLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, running.Seconds()))
+	}
+
+	d := prometheus.NewDesc("mongodb_currentop_slow_count",
+		"The number of operations running for longer than the slow threshold", nil, labels)
+	metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(slowCount)))
+
+	return metrics
+}
+
+// opRunningTime returns the running time of an operation in currentOp, preferring the microseconds
+// precision field when available.
+func opRunningTime(op bson.M) (time.Duration, error) {
+	if v, ok := op["microsecs_running"]; ok {
+		f, err := asFloat64(v)
+		if err != nil || f == nil {
+			return 0, errUnexpectedDataType
+		}
+
+		return time.Duration(*f) * time.Microsecond, nil
+	}
+
+	f, err := asFloat64(op["secs_running"])
+	if err != nil || f == nil {
+		return 0, errUnexpectedDataType
+	}
+
+	return time.Duration(*f) * time.Second, nil
+}
+
+var _ prometheus.Collector = (*currentopCollector)(nil)
@@ -0,0 +1,82 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCurrentOpMetrics(t *testing.T) {
+	inprog := primitive.A{
+		bson.M{
+			"opid":              int32(1234),
+			"op":                "query",
+			"ns":                "testdb.testcol",
+			"secs_running":      int64(90),
+			"microsecs_running": int64(90500000),
+		},
+		bson.M{
+			"opid":              int32(1235),
+			"op":                "insert",
+			"ns":                "testdb.testcol",
+			"secs_running":      int64(1),
+			"microsecs_running": int64(1200000),
+		},
+		bson.M{
+			// mongos operations have a shard prefixed string opid and old servers have no microsecs_running.
+			"opid":         "rs1:42",
+			"op":           "command",
+			"ns":           "admin.$cmd",
+			"secs_running": int32(70),
+		},
+		bson.M{
+			// The internal operations might have no op nor ns.
+			"opid":         int32(7),
+			"secs_running": int64(120),
+		},
+		"not a document",
+	}
+
+	want := []string{
+		"# HELP mongodb_currentop_query_uptime_seconds The time an operation running for longer than the slow threshold has been running",
+		"# TYPE mongodb_currentop_query_uptime_seconds gauge",
+		`mongodb_currentop_query_uptime_seconds{ns="",op="",opid="7"} 120`,
+		`mongodb_currentop_query_uptime_seconds{ns="admin.$cmd",op="command",opid="rs1:42"} 70`,
+		`mongodb_currentop_query_uptime_seconds{ns="testdb.testcol",op="query",opid="1234"} 90.5`,
+		"# HELP mongodb_currentop_slow_count The number of operations running for longer than the slow threshold",
+		"# TYPE mongodb_currentop_slow_count gauge",
+		"mongodb_currentop_slow_count 3",
+	}
+
+	metrics := currentOpMetrics(inprog, time.Minute, map[string]string{})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	want = []string{
+		"# HELP mongodb_currentop_slow_count The number of operations running for longer than the slow threshold",
+		"# TYPE mongodb_currentop_slow_count gauge",
+		"mongodb_currentop_slow_count 0",
+	}
+
+	metrics = currentOpMetrics(inprog, time.Hour, map[string]string{})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
@@ -0,0 +1,96 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// cursorCollector exposes the open and timed out cursors from serverStatus().metrics.cursor, useful
+// to detect cursor leaks in the applications.
+type cursorCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *cursorCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *cursorCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *cursorCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range cursorMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// cursorMetrics uses metrics.cursor, with the open cursors in the metrics.cursor.open subdocument.
+// Old servers without it have the same counters in the cursors section.
+func cursorMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	openPath := []string{"metrics", "cursor", "open"}
+	openFields := map[string]string{"total": "total", "noTimeout": "noTimeout", "pinned": "pinned"}
+	timedOutPath := []string{"metrics", "cursor", "timedOut"}
+
+	if _, ok := walkTo(m, openPath).(bson.M); !ok {
+		openPath = []string{"cursors"}
+		openFields = map[string]string{"total": "totalOpen", "noTimeout": "totalNoTimeout", "pinned": "pinned"}
+		timedOutPath = []string{"cursors", "timedOut"}
+	}
+
+	defs := make([]fieldMetric, 0, len(openFields)+1)
+
+	for typ, field := range openFields {
+		path := append(append([]string{}, openPath...), field)
+		defs = append(defs, fieldMetric{
+			path:   path,
+			name:   "mongodb_cursor_open",
+			help:   "Number of cursors open, by type",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": typ},
+		})
+	}
+
+	defs = append(defs, fieldMetric{
+		path: timedOutPath,
+		name: "mongodb_cursor_timed_out_total",
+		help: "Number of cursors that have timed out since the server started",
+		vt:   prometheus.CounterValue,
+	})
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*cursorCollector)(nil)
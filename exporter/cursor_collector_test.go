@@ -0,0 +1,68 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCursorMetrics(t *testing.T) {
+	want := []string{
+		"# HELP mongodb_cursor_open Number of cursors open, by type",
+		"# TYPE mongodb_cursor_open gauge",
+		`mongodb_cursor_open{rs_nm="rs1",type="noTimeout"} 1`,
+		`mongodb_cursor_open{rs_nm="rs1",type="pinned"} 2`,
+		`mongodb_cursor_open{rs_nm="rs1",type="total"} 5`,
+		"# HELP mongodb_cursor_timed_out_total Number of cursors that have timed out since the server started",
+		"# TYPE mongodb_cursor_timed_out_total counter",
+		`mongodb_cursor_timed_out_total{rs_nm="rs1"} 3`,
+	}
+	labels := map[string]string{labelReplicasetName: "rs1"}
+
+	m := bson.M{
+		"metrics": bson.M{
+			"cursor": bson.M{
+				"timedOut": int64(3),
+				"open": bson.M{
+					"noTimeout":    int64(1),
+					"pinned":       int64(2),
+					"total":        int64(5),
+					"singleTarget": int64(0),
+					"multiTarget":  int64(0),
+				},
+			},
+		},
+	}
+	assert.Equal(t, want, helpers.Format(cursorMetrics(m, labels)))
+
+	// Old servers have the counters in the cursors section.
+	m = bson.M{
+		"cursors": bson.M{
+			"totalOpen":      int32(5),
+			"totalNoTimeout": int32(1),
+			"pinned":         int32(2),
+			"timedOut":       int32(3),
+		},
+	}
+	assert.Equal(t, want, helpers.Format(cursorMetrics(m, labels)))
+
+	assert.Empty(t, cursorMetrics(bson.M{"ok": float64(1)}, labels))
+}
@@ -0,0 +1,314 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CustomQuery is an aggregation pipeline whose numeric result fields are exposed as metrics, with
+// one series per result document.
+type CustomQuery struct {
+	// Name identifies the query in the error metric and in the logs.
+	Name       string `yaml:"name"`
+	Database   string `yaml:"database"`
+	Collection string `yaml:"collection"`
+	// Pipeline is a JSON array of stages, in MongoDB Extended JSON, like [{"$group": {...}}].
+	Pipeline string `yaml:"pipeline"`
+	// Labels maps the label names to the result fields with their values. The fields can be
+	// nested, using dots.
+	Labels  map[string]string   `yaml:"labels"`
+	Metrics []CustomQueryMetric `yaml:"metrics"`
+}
+
+// CustomQueryMetric maps a numeric result field to a metric.
+type CustomQueryMetric struct {
+	Field string `yaml:"field"`
+	Name  string `yaml:"name"`
+	Help  string `yaml:"help"`
+	// Type is gauge, the default, or counter.
+	Type string `yaml:"type"`
+}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// customQuery is a validated CustomQuery, with the parsed pipeline.
+type customQuery struct {
+	CustomQuery
+	pipeline   []bson.D
+	labelNames []string
+	valueTypes []prometheus.ValueType
+}
+
+// parseCustomQueries validates the queries, so a wrong one is found when the exporter starts and
+// not on the scrapes. Since the queries can have different labels, a metric name can only be used
+// once across all of them.
+func parseCustomQueries(queries []CustomQuery) ([]customQuery, error) {
+	parsed := make([]customQuery, 0, len(queries))
+	names := make(map[string]bool, len(queries))
+	// The query of each metric name.
+	metricNames := make(map[string]string)
+
+	for _, q := range queries {
+		if q.Name == "" || q.Database == "" || q.Collection == "" {
+			return nil, errors.Errorf("custom query %q: the name, the database and the collection are required", q.Name)
+		}
+
+		if names[q.Name] {
+			return nil, errors.Errorf("duplicated custom query name %q", q.Name)
+		}
+
+		names[q.Name] = true
+
+		cq, err := parseCustomQuery(q)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid custom query %q", q.Name)
+		}
+
+		for _, m := range q.Metrics {
+			if other, ok := metricNames[m.Name]; ok {
+				return nil, errors.Errorf("invalid custom query %q: metric %q is already used by the custom query %q",
+					q.Name, m.Name, other)
+			}
+
+			metricNames[m.Name] = q.Name
+		}
+
+		parsed = append(parsed, cq)
+	}
+
+	return parsed, nil
+}
+
+func parseCustomQuery(q CustomQuery) (customQuery, error) {
+	cq := customQuery{CustomQuery: q}
+
+	var doc struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+
+	if err := bson.UnmarshalExtJSON([]byte(`{"pipeline": `+q.Pipeline+`}`), false, &doc); err != nil {
+		return cq, errors.Wrap(err, "cannot parse the pipeline")
+	}
+
+	for i, stage := range doc.Pipeline {
+		if len(stage) != 1 || !strings.HasPrefix(stage[0].Key, "$") {
+			return cq, errors.Errorf("pipeline stage %d must be a document with a single $ operator", i)
+		}
+
+		// The exporter only reads.
+		if stage[0].Key == "$out" || stage[0].Key == "$merge" {
+			return cq, errors.Errorf("pipeline stage %s is not allowed", stage[0].Key)
+		}
+	}
+
+	cq.pipeline = doc.Pipeline
+
+	for name := range q.Labels {
+		if !labelNameRe.MatchString(name) {
+			return cq, errors.Errorf("invalid label name %q", name)
+		}
+
+		switch name {
+		case labelClusterRole, labelClusterID, labelReplicasetName, labelReplicasetState, labelClusterName, labelMemberRole:
+			return cq, errors.Errorf("label name %q is used by the topology labels", name)
+		}
+
+		cq.labelNames = append(cq.labelNames, name)
+	}
+
+	sort.Strings(cq.labelNames)
+
+	if len(q.Metrics) == 0 {
+		return cq, errors.New("no metrics")
+	}
+
+	seen := make(map[string]bool, len(q.Metrics))
+
+	for _, m := range q.Metrics {
+		if m.Field == "" || !metricsPrefixRe.MatchString(m.Name) {
+			return cq, errors.Errorf("metric %q needs a field and a valid name", m.Name)
+		}
+
+		if seen[m.Name] {
+			return cq, errors.Errorf("duplicated metric %q", m.Name)
+		}
+
+		seen[m.Name] = true
+
+		if isExporterMetricName(m.Name) {
+			return cq, errors.Errorf("metric %q is exported by the exporter", m.Name)
+		}
+
+		switch m.Type {
+		case "", "gauge":
+			cq.valueTypes = append(cq.valueTypes, prometheus.GaugeValue)
+		case "counter":
+			cq.valueTypes = append(cq.valueTypes, prometheus.CounterValue)
+		default:
+			return cq, errors.Errorf("metric %q has an invalid type %q, it must be gauge or counter", m.Name, m.Type)
+		}
+	}
+
+	return cq, nil
+}
+
+// exporterMetricNames are the metrics always exported, not generated from the server documents.
+var exporterMetricNames = []string{"mongodb_up", "mongodb_server_version_info", "mongodb_custom_query_error"}
+
+// isExporterMetricName returns true if the exporter might export the metric too, which would fail
+// the scrapes: the general metrics, the exporter and collector ones, and the metrics generated from
+// the server documents, like the mongodb_ss_ ones from serverStatus.
+func isExporterMetricName(name string) bool {
+	for _, n := range exporterMetricNames {
+		if name == n {
+			return true
+		}
+	}
+
+	reserved := []string{"mongodb_exporter_", "mongodb_collector_"}
+	for _, pair := range prefixes {
+		reserved = append(reserved, "mongodb_"+pair[1]+"_")
+	}
+
+	for _, prefix := range reserved {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// customQueryCollector runs the custom aggregations and exposes their mapped results.
+type customQueryCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	queries      []customQuery
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *customQueryCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *customQueryCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *customQueryCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, q := range d.queries {
+		docs, err := d.run(q)
+		if err != nil {
+			d.logger.Errorf("cannot run the custom query %s: %s", q.Name, err)
+			d.fail()
+		}
+
+		ch <- customQueryErrorMetric(q.Name, err != nil, d.topologyInfo.baseLabels())
+
+		if err != nil {
+			continue
+		}
+
+		for _, metric := range customQueryMetrics(q, docs, d.topologyInfo.baseLabels()) {
+			ch <- metric
+		}
+	}
+}
+
+func (d *customQueryCollector) run(q customQuery) ([]bson.M, error) {
+	cursor, err := d.client.Database(q.Database).Collection(q.Collection).Aggregate(d.ctx, q.pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []bson.M
+	if err := cursor.All(d.ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// customQueryErrorMetric returns 1 if the last run of the query failed.
+func customQueryErrorMetric(name string, failed bool, labels map[string]string) prometheus.Metric {
+	labels["query"] = name
+
+	var value float64
+	if failed {
+		value = 1
+	}
+
+	d := prometheus.NewDesc("mongodb_custom_query_error", "Whether the last run of the custom query failed", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, value)
+}
+
+// customQueryMetrics maps the result documents to the query metrics. The fields that are missing or
+// not numeric are skipped, like the result documents with the same label values than a previous one.
+func customQueryMetrics(q customQuery, docs []bson.M, labels map[string]string) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	seen := make(map[string]bool, len(docs))
+
+	for _, doc := range docs {
+		values := make([]string, 0, len(q.labelNames))
+
+		for _, name := range q.labelNames {
+			value := ""
+			if v := walkTo(doc, strings.Split(q.Labels[name], ".")); v != nil {
+				value = fmt.Sprint(v)
+			}
+
+			values = append(values, value)
+		}
+
+		key := strings.Join(values, "\xff")
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		for i, m := range q.Metrics {
+			value, err := asFloat64(walkTo(doc, strings.Split(m.Field, ".")))
+			if err != nil || value == nil {
+				continue
+			}
+
+			d := prometheus.NewDesc(m.Name, m.Help, q.labelNames, labels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, q.valueTypes[i], *value, values...))
+		}
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*customQueryCollector)(nil)
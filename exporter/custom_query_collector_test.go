@@ -0,0 +1,131 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseCustomQueries(t *testing.T) {
+	query := CustomQuery{
+		Name:       "orders",
+		Database:   "shop",
+		Collection: "orders",
+		Pipeline:   `[{"$match": {"created": {"$gte": {"$date": "2021-01-01T00:00:00Z"}}}}, {"$group": {"_id": "$status", "count": {"$sum": 1}}}]`,
+		Labels:     map[string]string{"status": "_id"},
+		Metrics:    []CustomQueryMetric{{Field: "count", Name: "mongodb_shop_orders", Help: "Number of orders"}},
+	}
+
+	queries, err := parseCustomQueries([]CustomQuery{query})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Len(t, queries[0].pipeline, 2)
+	assert.Equal(t, "$group", queries[0].pipeline[1][0].Key)
+
+	for name, modify := range map[string]func(q *CustomQuery){
+		"no database":      func(q *CustomQuery) { q.Database = "" },
+		"not json":         func(q *CustomQuery) { q.Pipeline = `[{"$match": ` },
+		"not an operator":  func(q *CustomQuery) { q.Pipeline = `[{"status": "new"}]` },
+		"write stage":      func(q *CustomQuery) { q.Pipeline = `[{"$out": "copy"}]` },
+		"topology label":   func(q *CustomQuery) { q.Labels = map[string]string{labelReplicasetName: "_id"} },
+		"no metrics":       func(q *CustomQuery) { q.Metrics = nil },
+		"bad metric name":  func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Field: "n", Name: "n-n"}} },
+		"bad metric type":  func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Field: "n", Name: "n", Type: "summary"}} },
+		"bad label name":   func(q *CustomQuery) { q.Labels = map[string]string{"order status": "_id"} },
+		"no metric fields": func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Name: "orders"}} },
+		"duplicated metric": func(q *CustomQuery) {
+			q.Metrics = []CustomQueryMetric{{Field: "count", Name: "orders"}, {Field: "amount", Name: "orders"}}
+		},
+		"general metric":      func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Field: "n", Name: "mongodb_up"}} },
+		"collector metric":    func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Field: "n", Name: "mongodb_collector_success"}} },
+		"serverStatus metric": func(q *CustomQuery) { q.Metrics = []CustomQueryMetric{{Field: "n", Name: "mongodb_ss_connections"}} },
+		"custom error metric": func(q *CustomQuery) {
+			q.Metrics = []CustomQueryMetric{{Field: "n", Name: "mongodb_custom_query_error"}}
+		},
+		"exporter own metric": func(q *CustomQuery) {
+			q.Metrics = []CustomQueryMetric{{Field: "n", Name: "mongodb_exporter_scrape_in_progress"}}
+		},
+	} {
+		q := query
+		modify(&q)
+
+		_, err := parseCustomQueries([]CustomQuery{q})
+		assert.Error(t, err, name)
+	}
+
+	_, err = parseCustomQueries([]CustomQuery{query, query})
+	assert.EqualError(t, err, `duplicated custom query name "orders"`)
+
+	// The other queries could have other labels.
+	other := query
+	other.Name = "orders_by_region"
+	other.Labels = map[string]string{"region": "_id"}
+
+	_, err = parseCustomQueries([]CustomQuery{query, other})
+	assert.EqualError(t, err,
+		`invalid custom query "orders_by_region": metric "mongodb_shop_orders" is already used by the custom query "orders"`)
+}
+
+func TestCustomQueryMetrics(t *testing.T) {
+	queries, err := parseCustomQueries([]CustomQuery{{
+		Name:       "orders",
+		Database:   "shop",
+		Collection: "orders",
+		Pipeline:   `[{"$group": {"_id": "$status", "count": {"$sum": 1}, "amount": {"$sum": "$amount"}}}]`,
+		Labels:     map[string]string{"status": "_id", "region": "meta.region"},
+		Metrics: []CustomQueryMetric{
+			{Field: "count", Name: "mongodb_shop_orders", Help: "Number of orders"},
+			{Field: "amount.total", Name: "mongodb_shop_orders_amount_total", Help: "Orders amount", Type: "counter"},
+		},
+	}})
+	require.NoError(t, err)
+
+	docs := []bson.M{
+		{"_id": "new", "count": int32(3), "amount": bson.M{"total": 120.5}, "meta": bson.M{"region": "eu"}},
+		{"_id": "paid", "count": int64(5), "amount": bson.M{"total": "n/a"}},
+		// Same labels than the first one.
+		{"_id": "new", "count": int32(7), "meta": bson.M{"region": "eu"}},
+	}
+
+	want := []string{
+		"# HELP mongodb_shop_orders Number of orders",
+		"# TYPE mongodb_shop_orders gauge",
+		`mongodb_shop_orders{region="",rs_nm="rs1",status="paid"} 5`,
+		`mongodb_shop_orders{region="eu",rs_nm="rs1",status="new"} 3`,
+		"# HELP mongodb_shop_orders_amount_total Orders amount",
+		"# TYPE mongodb_shop_orders_amount_total counter",
+		`mongodb_shop_orders_amount_total{region="eu",rs_nm="rs1",status="new"} 120.5`,
+	}
+
+	metrics := customQueryMetrics(queries[0], docs, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	want = []string{
+		"# HELP mongodb_custom_query_error Whether the last run of the custom query failed",
+		"# TYPE mongodb_custom_query_error gauge",
+		`mongodb_custom_query_error{query="orders",rs_nm="rs1"} 1`,
+	}
+
+	metric := customQueryErrorMetric("orders", true, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format([]prometheus.Metric{metric}))
+}
@@ -0,0 +1,151 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type dbstatsCollector struct {
+	collectFailure
+
+	ctx             context.Context
+	client          *mongo.Client
+	databases       []string
+	discoveringMode bool
+	logger          *logrus.Logger
+	topologyInfo    labelsGetter
+}
+
+func (d *dbstatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *dbstatsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *dbstatsCollector) Collect(ch chan<- prometheus.Metric) {
+	databases := d.databases
+
+	if len(databases) == 0 && d.discoveringMode {
+		dbNames, err := d.client.ListDatabaseNames(d.ctx, bson.D{})
+		if err != nil {
+			d.logger.Errorf("cannot get the database names list: %s", err)
+			d.fail()
+
+			return
+		}
+
+		databases = filterSystemDatabases(dbNames)
+	}
+
+	for _, database := range databases {
+		if database == "" {
+			continue
+		}
+
+		var m bson.M
+
+		cmd := bson.D{{Key: "dbStats", Value: 1}, {Key: "scale", Value: 1}}
+		if err := d.client.Database(database).RunCommand(d.ctx, cmd).Decode(&m); err != nil {
+			d.logger.Errorf("cannot get dbStats for database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		d.logger.Debugf("dbStats metrics for %s", database)
+		debugResult(d.logger, m)
+
+		labels := d.topologyInfo.baseLabels()
+		labels["database"] = database
+
+		for _, metric := range databaseStatsMetrics(m, labels) {
+			ch <- metric
+		}
+	}
+}
+
+func databaseStatsMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"collections"},
+			name: "mongodb_dbstats_collections",
+			help: "Number of collections in the database",
+		},
+		{
+			path: []string{"views"},
+			name: "mongodb_dbstats_views",
+			help: "Number of views in the database",
+		},
+		{
+			path: []string{"objects"},
+			name: "mongodb_dbstats_objects",
+			help: "Number of objects (documents) in the database across all collections",
+		},
+		{
+			path: []string{"dataSize"},
+			name: "mongodb_dbstats_dataSize",
+			help: "Total size in bytes of the uncompressed data held in the database",
+		},
+		{
+			path: []string{"storageSize"},
+			name: "mongodb_dbstats_storageSize",
+			help: "Total amount of space in bytes allocated to collections in the database for document storage",
+		},
+		{
+			path: []string{"indexes"},
+			name: "mongodb_dbstats_indexes",
+			help: "Total number of indexes across all collections in the database",
+		},
+		{
+			path: []string{"indexSize"},
+			name: "mongodb_dbstats_indexSize",
+			help: "Total size in bytes of all indexes created on the database",
+		},
+	}
+
+	for i := range defs {
+		defs[i].vt = prometheus.GaugeValue
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+// filterSystemDatabases removes the MongoDB internal databases from the list.
+func filterSystemDatabases(databases []string) []string {
+	filtered := make([]string, 0, len(databases))
+
+	for _, db := range databases {
+		switch db {
+		case "admin", "config", "local":
+			continue
+		default:
+			filtered = append(filtered, db)
+		}
+	}
+
+	return filtered
+}
+
+var _ prometheus.Collector = (*dbstatsCollector)(nil)
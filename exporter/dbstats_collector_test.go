@@ -0,0 +1,106 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/percona/mongodb_exporter/internal/tu"
+)
+
+func TestDBStatsCollector(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	client := tu.DefaultTestClient(ctx, t)
+
+	database := client.Database("testdbstats")
+	database.Drop(ctx) //nolint:errcheck
+
+	defer func() {
+		err := database.Drop(ctx)
+		assert.NoError(t, err)
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err := database.Collection("testcol").InsertOne(ctx, bson.M{"f1": i})
+		assert.NoError(t, err)
+	}
+
+	c := &dbstatsCollector{
+		ctx:          ctx,
+		client:       client,
+		databases:    []string{"testdbstats"},
+		logger:       logrus.New(),
+		topologyInfo: labelsGetterMock{},
+	}
+
+	// The last \n at the end of this string is important
+	expected := strings.NewReader(`
+# HELP mongodb_dbstats_collections Number of collections in the database
+# TYPE mongodb_dbstats_collections gauge
+mongodb_dbstats_collections{database="testdbstats"} 1
+# HELP mongodb_dbstats_objects Number of objects (documents) in the database across all collections
+# TYPE mongodb_dbstats_objects gauge
+mongodb_dbstats_objects{database="testdbstats"} 3` + "\n")
+
+	filter := []string{
+		"mongodb_dbstats_collections",
+		"mongodb_dbstats_objects",
+	}
+	err := testutil.CollectAndCompare(c, expected, filter...)
+	assert.NoError(t, err)
+}
+
+func TestDatabaseStatsMetrics(t *testing.T) {
+	m := bson.M{
+		"db":          "testdb",
+		"collections": int32(2),
+		"objects":     int64(15),
+		"dataSize":    float64(1024),
+		"ok":          float64(1),
+	}
+
+	want := []string{
+		"# HELP mongodb_dbstats_collections Number of collections in the database",
+		"# TYPE mongodb_dbstats_collections gauge",
+		`mongodb_dbstats_collections{database="testdb"} 2`,
+		"# HELP mongodb_dbstats_dataSize Total size in bytes of the uncompressed data held in the database",
+		"# TYPE mongodb_dbstats_dataSize gauge",
+		`mongodb_dbstats_dataSize{database="testdb"} 1024`,
+		"# HELP mongodb_dbstats_objects Number of objects (documents) in the database across all collections",
+		"# TYPE mongodb_dbstats_objects gauge",
+		`mongodb_dbstats_objects{database="testdb"} 15`,
+	}
+
+	metrics := databaseStatsMetrics(m, map[string]string{"database": "testdb"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
+
+func TestFilterSystemDatabases(t *testing.T) {
+	dbs := []string{"admin", "config", "local", "testdb", "app"}
+	assert.Equal(t, []string{"testdb", "app"}, filterSystemDatabases(dbs))
+}
@@ -27,9 +27,13 @@ import (
 )
 
 type diagnosticDataCollector struct {
+	collectFailure
+
 	ctx            context.Context
 	client         *mongo.Client
 	compatibleMode bool
+	remaps         *compatibleRemaps
+	maxBytes       int
 	logger         *logrus.Logger
 	topologyInfo   labelsGetter
 }
@@ -38,14 +42,33 @@ func (d *diagnosticDataCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
+func (d *diagnosticDataCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
 func (d *diagnosticDataCollector) Collect(ch chan<- prometheus.Metric) {
 	var m bson.M
 
 	cmd := bson.D{{Key: "getDiagnosticData", Value: "1"}}
-	res := d.client.Database("admin").RunCommand(d.ctx, cmd)
-
-	if err := res.Decode(&m); err != nil {
+	raw, err := d.client.Database("admin").RunCommand(d.ctx, cmd).DecodeBytes()
+	if err != nil {
 		d.logger.Errorf("cannot run getDiagnosticData: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range diagnosticDataSizeMetrics(len(raw), d.maxBytes, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+
+	if d.maxBytes > 0 && len(raw) > d.maxBytes {
+		d.logger.Warnf("getDiagnosticData returned %d bytes, more than the limit of %d", len(raw), d.maxBytes)
+	}
+
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		d.logger.Errorf("cannot decode getDiagnosticData: %s", err)
+		d.fail()
 
 		return
 	}
@@ -54,6 +77,7 @@ func (d *diagnosticDataCollector) Collect(ch chan<- prometheus.Metric) {
 	if !ok {
 		err := errors.Wrapf(errUnexpectedDataType, "%T for data field", m["data"])
 		d.logger.Errorf("cannot decode getDiagnosticData: %s", err)
+		d.fail()
 
 		return
 	}
@@ -61,22 +85,11 @@ func (d *diagnosticDataCollector) Collect(ch chan<- prometheus.Metric) {
 	d.logger.Debug("getDiagnosticData result")
 	debugResult(d.logger, m)
 
-	metrics := makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode)
+	metrics := makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode, d.remaps)
 	metrics = append(metrics, locksMetrics(m)...)
 
 	if d.compatibleMode {
-		metrics = append(metrics, specialMetrics(d.ctx, d.client, m, d.logger)...)
-
-		if cem, err := cacheEvictedTotalMetric(m); err == nil {
-			metrics = append(metrics, cem)
-		}
-
-		nodeType, err := getNodeType(d.ctx, d.client)
-		if err != nil {
-			d.logger.Errorf("Cannot get node type to check if this is a mongos: %s", err)
-		} else if nodeType == typeMongos {
-			metrics = append(metrics, mongosMetrics(d.ctx, d.client, d.logger)...)
-		}
+		metrics = append(metrics, d.compatibleMetrics(m)...)
 	}
 
 	for _, metric := range metrics {
@@ -84,5 +97,47 @@ func (d *diagnosticDataCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+// compatibleMetrics returns the metrics in the old format that are not copies of the new ones, made
+// by makeMetrics.
+func (d *diagnosticDataCollector) compatibleMetrics(m bson.M) []prometheus.Metric {
+	metrics := specialMetrics(d.ctx, d.client, m, d.logger)
+
+	if cem, err := cacheEvictedTotalMetric(m); err == nil {
+		metrics = append(metrics, cem)
+	}
+
+	nodeType, err := getNodeType(d.ctx, d.client)
+	if err != nil {
+		d.logger.Errorf("Cannot get node type to check if this is a mongos: %s", err)
+		d.fail()
+	} else if nodeType == typeMongos {
+		metrics = append(metrics, mongosMetrics(d.ctx, d.client, d.logger)...)
+	}
+
+	d.remaps.add(metrics)
+
+	return metrics
+}
+
+// diagnosticDataSizeMetrics returns the size of the getDiagnosticData result and, if there is a
+// limit, whether the size is over it.
+func diagnosticDataSizeMetrics(size, maxBytes int, labels map[string]string) []prometheus.Metric {
+	d := prometheus.NewDesc("mongodb_diagnostic_data_bytes", "Size of the getDiagnosticData result", nil, labels)
+	metrics := []prometheus.Metric{prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(size))}
+
+	if maxBytes > 0 {
+		var exceeded float64
+		if size > maxBytes {
+			exceeded = 1
+		}
+
+		d := prometheus.NewDesc("mongodb_diagnostic_data_max_bytes_exceeded",
+			"Whether the getDiagnosticData result is bigger than the configured limit", nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, exceeded))
+	}
+
+	return metrics
+}
+
 // check interface.
 var _ prometheus.Collector = (*diagnosticDataCollector)(nil)
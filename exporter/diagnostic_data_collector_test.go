@@ -119,3 +119,21 @@ func TestAllDiagnosticDataCollectorMetrics(t *testing.T) {
 		assert.True(t, metricNames[want], fmt.Sprintf("missing %q metric", want))
 	}
 }
+
+func TestDiagnosticDataSizeMetrics(t *testing.T) {
+	want := []string{
+		"# HELP mongodb_diagnostic_data_bytes Size of the getDiagnosticData result",
+		"# TYPE mongodb_diagnostic_data_bytes gauge",
+		`mongodb_diagnostic_data_bytes{rs_nm="rs1"} 2.5e+06`,
+		"# HELP mongodb_diagnostic_data_max_bytes_exceeded Whether the getDiagnosticData result is bigger than the configured limit",
+		"# TYPE mongodb_diagnostic_data_max_bytes_exceeded gauge",
+		`mongodb_diagnostic_data_max_bytes_exceeded{rs_nm="rs1"} 1`,
+	}
+
+	metrics := diagnosticDataSizeMetrics(2500000, 2000000, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Without a limit, there is only the size.
+	metrics = diagnosticDataSizeMetrics(2500000, 0, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want[:3], helpers.Format(metrics))
+}
@@ -19,15 +19,29 @@ package exporter
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/percona/exporter_shared"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/tag"
 )
 
 // Exporter holds Exporter methods and attributes.
@@ -38,6 +52,32 @@ type Exporter struct {
 	opts             *Opts
 	webListenAddress string
 	topologyInfo     labelsGetter
+	collStatsCache   *collStatsCache
+	collStatsErrors  *collStatsErrors
+	planCacheEvict   *planCacheEvictions
+	customQueries    []customQuery
+	clientCache      *clientCache
+	// Clients of the config servers, only with the config servers collector.
+	configServerClients *clientCache
+	// Compiled MetricAllowRegex and MetricDenyRegex.
+	metricAllow *regexp.Regexp
+	metricDeny  *regexp.Regexp
+	// Collection lists from the options, replaced on reload.
+	collections    *collectionLists
+	collStatsTypes bson.D
+	// Protects client and topologyInfo, replaced on reconnection, collections and closed, set by Shutdown.
+	clientMu sync.Mutex
+	closed   bool
+	// Closed by Shutdown, to stop the background loops.
+	done chan struct{}
+	// Number of times each collector timed out. It must persist between scrapes.
+	collectorTimeouts *prometheus.CounterVec
+	// Number of metrics made by the compatible mode, only in compatible mode. Like the plan cache
+	// evictions, it's not used for the /scrape targets.
+	compatibleModeRemaps prometheus.Counter
+	// Scrapes state, in the default registry since it's not about MongoDB.
+	scrapesInProgress prometheus.Gauge
+	lastScrape        prometheus.Gauge
 }
 
 // Opts holds new exporter options.
@@ -54,11 +94,236 @@ type Opts struct {
 	Logger                  *logrus.Logger
 	DisableDiagnosticData   bool
 	DisableReplicasetStatus bool
+
+	EnableConnectionsCollector  bool
+	EnableOplogCollector        bool
+	EnableWiredTigerCollector   bool
+	EnableQueryMetrics          bool
+	EnableBalancerCollector     bool
+	EnableChunksCollector       bool
+	EnableTopCollector          bool
+	EnableOpcounters            bool
+	EnableLockCollector         bool
+	EnableTTLCollector          bool
+	EnableNetworkCollector      bool
+	EnableMemoryCollector       bool
+	EnableConfigServerCollector bool
+	EnableAssertsCollector      bool
+	EnableFlowControlCollector  bool
+	EnableCursorCollector       bool
+	EnableTransactionsCollector bool
+	EnableReplBufferCollector   bool
+	EnableCheckpointCollector   bool
+	EnableIndexBuildCollector   bool
+	EnableOperationMetrics      bool
+
+	// Logger settings, applied to Logger. LogLevel is a logrus level name and LogFormat is text
+	// or json. If empty, the Logger settings are kept.
+	LogLevel  string
+	LogFormat string
+
+	// Reconnect the global client if it cannot ping the server on a scrape. Only used with GlobalConnPool.
+	ReconnectOnFailure bool
+
+	// If set, Run also pushes the metrics to this Pushgateway every PushInterval (default 30 seconds),
+	// grouped by the MongoDB hosts and the topology labels.
+	PushGatewayURL string
+	PushInterval   time.Duration
+
+	// If set, Run reloads the CollStatsCollections and IndexStatsCollections lists returned by
+	// this function on SIGHUP, without closing the connections.
+	ReloadCollections func() (collStats, indexStats []string, err error)
+
+	// Without GlobalConnPool, keep the connection of each target between scrapes, closing it after
+	// ConnectionIdleTimeout without scrapes. If the timeout is zero, 5 minutes are used. The config
+	// server connections are always kept, and closed after the same timeout.
+	ConnectionReuse       bool
+	ConnectionIdleTimeout time.Duration
+
+	// Add the database and collection labels to the metrics with a namespace label. The namespace
+	// label is kept so, existing queries still work.
+	SplitNamespaceLabels bool
+
+	// Only report the replica set metrics of the member this exporter is connected to, for setups
+	// running an exporter next to every member.
+	LocalNodeOnly bool
+
+	// Value of the cl_name label added to all the metrics, to group the metrics of the members and
+	// the shards of a cluster. If empty, there is no cl_name label.
+	ClusterName string
+
+	// Use a pedantic registry, which checks the collected metrics are consistent with their
+	// descriptions on every scrape. It's slower, meant for testing the collectors.
+	PedanticRegistry bool
+
+	// Maximum number of collections matched by the db/regex patterns in CollStatsCollections, in
+	// discovering mode. If zero, there is no limit.
+	CollStatsMaxPatternMatches int
+
+	// Maximum number of indexes per collection with a mongodb_collstats_index_size_bytes metric. The
+	// first indexes by name are used. If zero, there is no limit.
+	CollStatsMaxIndexes int
+
+	// Only discover the collections of these types for $collStats, in discovering mode. The types
+	// are collection, view, timeseries, capped and clustered. If empty, all the collections are used.
+	CollStatsCollectionTypes []string
+
+	// Add the mongodb_collstats_latency_ops_total and mongodb_collstats_latency_micros_total counters
+	// by operation type, to get the average latency per collection.
+	CollStatsIncludeLatency bool
+
+	// Time to reuse the $collStats results. If zero, $collStats runs on every scrape.
+	CollStatsCacheTTL time.Duration
+
+	// Enables the /scrape?target=<uri> endpoint to get the metrics of any MongoDB instance.
+	// Credentials not present in the target are taken from the URI, and the authentication options
+	// are used, only if the target hosts are in the URI or MultiTargetCredentials is set. The client
+	// certificate is only sent to the hosts in the URI.
+	MultiTarget            bool
+	MultiTargetCredentials bool
+
+	// Prefix for the metric names, replacing mongodb. If empty, mongodb is used.
+	MetricsPrefix string
+
+	// Regexes for the names of the metrics to expose or to drop. They must match the whole name,
+	// before applying MetricsPrefix. MetricDenyRegex takes precedence.
+	MetricAllowRegex string
+	MetricDenyRegex  string
+
+	// Maximum time for each collector on every scrape. If zero, there is no limit besides the scrape timeout.
+	// CollectorTimeouts overrides it by collector name, like diagnosticdata.
+	CollectorTimeout  time.Duration
+	CollectorTimeouts map[string]time.Duration
+
+	// If positive, getDiagnosticData results bigger than this are logged and reported by
+	// mongodb_diagnostic_data_max_bytes_exceeded.
+	DiagnosticDataMaxBytes int
+
+	// indexStats discovery limits. If IndexStatsDatabases is not empty, in discovering mode, only
+	// these databases are used. MaxCollectionsPerDB caps the collections per database, zero means no limit.
+	IndexStatsDatabases []string
+	MaxCollectionsPerDB int
+
+	// Maximum number of collections discovered for $collStats and for $indexStats, across all the
+	// databases, in discovering mode. The first collections by namespace are used. Zero means no limit.
+	MaxDiscoveredCollections int
+
+	// Discover the system.* collections, like system.profile, for $collStats and $indexStats. The
+	// command line enables it by default, like the previous versions.
+	IncludeSystemCollections bool
+
+	// Expose the index accesses as the mongodb_indexstats_accesses_ops_total counter and the
+	// mongodb_indexstats_accesses_since_seconds gauge, instead of an untyped metric per index.
+	IndexStatsAccessCounters bool
+
+	// currentOp collector. Only operations running for longer than the threshold (default 1 minute) are reported.
+	EnableCurrentOp        bool
+	CurrentOpSlowThreshold time.Duration
+
+	// dbStats collector. If DBStatsDatabases is empty, in discovering mode all non-system databases are used.
+	EnableDBStats    bool
+	DBStatsDatabases []string
+
+	// Profiler collector. If ProfileDatabases is empty, in discovering mode all non-system databases are used.
+	EnableProfileCollector bool
+	ProfileDatabases       []string
+
+	// Collection document counts. If CollectionCountDatabases is empty, in discovering mode all
+	// non-system databases are used.
+	EnableCollectionCounts   bool
+	CollectionCountDatabases []string
+
+	// GridFS buckets storage. If GridFSDatabases is empty, in discovering mode all non-system
+	// databases are used.
+	EnableGridFSCollector bool
+	GridFSDatabases       []string
+
+	// Plan cache entries of the db.collection namespaces in PlanCacheCollections. There is no
+	// discovery, to keep the number of series bounded.
+	EnablePlanCacheCollector bool
+	PlanCacheCollections     []string
+
+	// Aggregations whose results are exposed as metrics. They are validated by New.
+	CustomQueries []CustomQuery
+
+	// TLS settings for the MongoDB connection. They override the TLS options in the URI.
+	TLSCertificateKeyFile string
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+
+	// Timeouts for the MongoDB connection. If zero, the URI settings or a 5 seconds default are used.
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+
+	// Number of connection retries in New, waiting ConnectRetryInterval (default 1 second) before the
+	// first retry and doubling it on each retry. If zero, New fails on the first connection error.
+	ConnectRetries       int
+	ConnectRetryInterval time.Duration
+
+	// Read preference mode (primary, primaryPreferred, secondary, secondaryPreferred or nearest).
+	// Admin commands like serverStatus always run on the primary unless the connection is direct.
+	ReadPreference string
+
+	// Read preference tags, as key:value pairs, to only read from the members with all these tags.
+	// They need a read preference mode other than primary, from ReadPreference or from the URI.
+	ReadPreferenceTags []string
+
+	// Basic authentication credentials for the metrics and the scrape endpoints, not for the health
+	// checks. They take precedence over the HTTP_AUTH environment variable.
+	WebAuthUsername string
+	WebAuthPassword string
+
+	// Certificate and key files to serve the exporter endpoints over HTTPS. With WebTLSClientCAFile,
+	// the clients must present a certificate signed by one of its CAs.
+	WebTLSCertFile     string
+	WebTLSKeyFile      string
+	WebTLSClientCAFile string
+
+	// Timeout for the MongoDB ping done by the /ready endpoint. If zero, 2 seconds are used.
+	ReadinessTimeout time.Duration
+
+	// Connection pool size limits. If zero, the URI settings or the driver defaults are used.
+	MaxPoolSize uint64
+	MinPoolSize uint64
+
+	// Application name sent to MongoDB, shown in currentOp and in the server logs. If empty,
+	// mongodb_exporter is used.
+	AppName string
+
+	// Wire protocol compressors, in order of preference: zstd, snappy or zlib. The server uses the
+	// first one it supports. They override the compressors option in the URI.
+	Compressors []string
+
+	// Authentication settings. They override the authMechanism and authSource options in the URI.
+	AuthMechanism string
+	AuthSource    string
+
+	// Files with the credentials, to keep them out of the URI and the command line.
+	// If URIFile is set, it replaces the URI. The credentials files override the ones in the URI.
+	URIFile      string
+	UsernameFile string
+	PasswordFile string
+
+	// File with the session token of temporary AWS credentials, for the MONGODB-AWS mechanism. The
+	// access key ID and the secret access key are the username and password.
+	AWSSessionTokenFile string
 }
 
+const (
+	defaultConnectTimeout         = 5 * time.Second
+	defaultServerSelectionTimeout = 5 * time.Second
+	defaultConnectRetryInterval   = time.Second
+	defaultConnectionIdleTimeout  = 5 * time.Minute
+	defaultAppName                = "mongodb_exporter"
+
+	authMechanismAWS = "MONGODB-AWS"
+)
+
 var (
 	errCannotHandleType   = fmt.Errorf("don't know how to handle data type")
 	errUnexpectedDataType = fmt.Errorf("unexpected data type")
+	errInvalidCAFile      = fmt.Errorf("no valid certificates found")
+	errExporterClosed     = fmt.Errorf("the exporter is shut down")
 )
 
 // New connects to the database and returns a new Exporter instance.
@@ -71,6 +336,37 @@ func New(opts *Opts) (*Exporter, error) {
 		opts.Logger = logrus.New()
 	}
 
+	if err := configureLogger(opts.Logger, opts.LogLevel, opts.LogFormat); err != nil {
+		return nil, err
+	}
+
+	if opts.URIFile != "" {
+		uri, err := readSecretFile(opts.URIFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read URI file")
+		}
+
+		opts.URI = uri
+	}
+
+	opts.URI = normalizeURI(opts.URI)
+	opts.Logger.Debugf("Connection URI: %s", redactURI(opts.URI))
+
+	opts.MetricsPrefix = strings.TrimSuffix(opts.MetricsPrefix, "_")
+	if opts.MetricsPrefix != "" && !metricsPrefixRe.MatchString(opts.MetricsPrefix) {
+		return nil, errors.Errorf("invalid metrics prefix %q", opts.MetricsPrefix)
+	}
+
+	metricAllow, err := compileMetricNameRegex(opts.MetricAllowRegex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid metric allow regex")
+	}
+
+	metricDeny, err := compileMetricNameRegex(opts.MetricDenyRegex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid metric deny regex")
+	}
+
 	ctx := context.Background()
 
 	exp := &Exporter{
@@ -78,10 +374,73 @@ func New(opts *Opts) (*Exporter, error) {
 		logger:           opts.Logger,
 		opts:             opts,
 		webListenAddress: opts.WebListenAddress,
+		done:             make(chan struct{}),
+		metricAllow:      metricAllow,
+		metricDeny:       metricDeny,
+		collectorTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mongodb_collector_timeout_total",
+			Help: "Number of times the collector was cut off by the collector timeout",
+		}, []string{"collector"}),
+		scrapesInProgress: registerDefault(prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mongodb_exporter_scrape_in_progress",
+			Help: "Number of scrapes in progress, including the current one",
+		})).(prometheus.Gauge),
+		lastScrape: registerDefault(prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mongodb_exporter_last_scrape_timestamp_seconds",
+			Help: "Time when the last scrape finished, as a Unix timestamp",
+		})).(prometheus.Gauge),
+	}
+
+	if opts.CompatibleMode {
+		exp.compatibleModeRemaps = newCompatibleModeRemapsCounter()
+	}
+
+	exp.collections, err = newCollectionLists(opts.CollStatsCollections, opts.IndexStatsCollections)
+	if err != nil {
+		return nil, err
+	}
+
+	exp.collStatsTypes, err = collectionTypesFilter(opts.CollStatsCollectionTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	exp.customQueries, err = parseCustomQueries(opts.CustomQueries)
+	if err != nil {
+		return nil, err
 	}
+
+	if len(exp.collections.collStatsPatterns) > 0 && !opts.DiscoveringMode {
+		opts.Logger.Warn("The collstats collection patterns are only used in discovering mode")
+	}
+
+	if len(opts.CollStatsCollectionTypes) > 0 && !opts.DiscoveringMode {
+		opts.Logger.Warn("The collstats collection types are only used in discovering mode")
+	}
+
+	if opts.CollStatsCacheTTL > 0 {
+		exp.collStatsCache = newCollStatsCache(opts.CollStatsCacheTTL)
+	}
+
+	exp.collStatsErrors = newCollStatsErrors()
+	exp.planCacheEvict = newPlanCacheEvictions()
+
+	idleTimeout := opts.ConnectionIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultConnectionIdleTimeout
+	}
+
+	if opts.ConnectionReuse && !opts.GlobalConnPool {
+		exp.clientCache = newClientCache(opts, idleTimeout)
+	}
+
+	// The config servers are not connected on each scrape, like the target.
+	if opts.EnableConfigServerCollector {
+		exp.configServerClients = newClientCache(opts, idleTimeout)
+	}
+
 	if opts.GlobalConnPool {
-		var err error
-		exp.client, err = connect(ctx, opts.URI, opts.DirectConnect)
+		exp.client, err = connectWithRetries(ctx, opts.URI, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -95,56 +454,430 @@ func New(opts *Opts) (*Exporter, error) {
 	return exp, nil
 }
 
+// configureLogger sets the logger level and format, if not empty.
+func configureLogger(logger *logrus.Logger, level, format string) error {
+	if level != "" {
+		l, err := logrus.ParseLevel(level)
+		if err != nil {
+			return errors.Wrap(err, "invalid log level")
+		}
+
+		logger.SetLevel(l)
+	}
+
+	switch format {
+	case "":
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return errors.Errorf("invalid log format %q, valid formats: text, json", format)
+	}
+
+	return nil
+}
+
 func (e *Exporter) makeRegistry(ctx context.Context, client *mongo.Client, topologyInfo labelsGetter) *prometheus.Registry {
-	// TODO: use NewPedanticRegistry when mongodb_exporter code fulfils its requirements (https://jira.percona.com/browse/PMM-6630).
+	if e.opts.ClusterName != "" {
+		topologyInfo = clusterNameLabels{labelsGetter: topologyInfo, name: e.opts.ClusterName}
+	}
+
+	// TODO: use NewPedanticRegistry by default when mongodb_exporter code fulfils its requirements (https://jira.percona.com/browse/PMM-6630).
 	registry := prometheus.NewRegistry()
+	if e.opts.PedanticRegistry {
+		registry = prometheus.NewPedanticRegistry()
+	}
+
+	if e.opts.CollectorTimeout > 0 || len(e.opts.CollectorTimeouts) > 0 {
+		registry.MustRegister(e.collectorTimeouts)
+	}
+
+	remaps := &compatibleRemaps{counter: e.compatibleModeRemaps, logger: e.logger}
+	if e.compatibleModeRemaps != nil {
+		registry.MustRegister(e.compatibleModeRemaps)
+	}
+
+	// The collectors reading serverStatus share it, instead of running it each.
+	serverStatus := newScrapeServerStatus(client)
 
 	gc := generalCollector{
-		ctx:    ctx,
-		client: client,
-		logger: e.opts.Logger,
+		ctx:          ctx,
+		client:       client,
+		logger:       e.opts.Logger,
+		topologyInfo: topologyInfo,
+		serverStatus: serverStatus,
 	}
-	registry.MustRegister(&gc)
+	registry.MustRegister(e.instrument(ctx, "general", &gc))
 
 	nodeType, err := getNodeType(ctx, client)
 	if err != nil {
 		e.logger.Errorf("Cannot get node type to check if this is a mongos: %s", err)
 	}
 
-	if len(e.opts.CollStatsCollections) > 0 {
+	// Arbiters have no data, so the collectors reading the databases would only log errors.
+	hasData := topologyInfo.baseLabels()[labelMemberRole] != string(roleArbiter)
+
+	lists := e.collectionLists()
+
+	if (len(lists.collStats) > 0 || len(lists.collStatsPatterns) > 0) && hasData {
 		cc := collstatsCollector{
+			ctx:               ctx,
+			client:            client,
+			collections:       lists.collStats,
+			compatibleMode:    e.opts.CompatibleMode,
+			remaps:            remaps,
+			discoveringMode:   e.opts.DiscoveringMode,
+			logger:            e.opts.Logger,
+			topologyInfo:      topologyInfo,
+			cache:             e.collStatsCache,
+			errors:            e.collStatsErrors,
+			patterns:          lists.collStatsPatterns,
+			maxPatternMatches: e.opts.CollStatsMaxPatternMatches,
+			maxIndexes:        e.opts.CollStatsMaxIndexes,
+			typesFilter:       e.collStatsTypes,
+			includeLatency:    e.opts.CollStatsIncludeLatency,
+			maxDiscovered:     e.opts.MaxDiscoveredCollections,
+			includeSystem:     e.opts.IncludeSystemCollections,
+		}
+		registry.MustRegister(e.instrument(ctx, "collstats", &cc))
+	}
+
+	if (len(lists.indexStats) > 0 || len(e.opts.IndexStatsDatabases) > 0) && hasData {
+		ic := indexstatsCollector{
+			ctx:                  ctx,
+			client:               client,
+			collections:          lists.indexStats,
+			discoveringMode:      e.opts.DiscoveringMode,
+			logger:               e.opts.Logger,
+			topologyInfo:         topologyInfo,
+			databases:            e.opts.IndexStatsDatabases,
+			maxCollectionsPerDB:  e.opts.MaxCollectionsPerDB,
+			splitNamespaceLabels: e.opts.SplitNamespaceLabels,
+			accessCounters:       e.opts.IndexStatsAccessCounters,
+			maxDiscovered:        e.opts.MaxDiscoveredCollections,
+			includeSystem:        e.opts.IncludeSystemCollections,
+		}
+		registry.MustRegister(e.instrument(ctx, "indexstats", &ic))
+	}
+
+	if !e.opts.DisableDiagnosticData {
+		ddc := diagnosticDataCollector{
+			ctx:            ctx,
+			client:         client,
+			compatibleMode: e.opts.CompatibleMode,
+			remaps:         remaps,
+			maxBytes:       e.opts.DiagnosticDataMaxBytes,
+			logger:         e.opts.Logger,
+			topologyInfo:   topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "diagnosticdata", &ddc))
+	}
+
+	if e.opts.EnableConnectionsCollector {
+		cc := connectionsCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "connections", &cc))
+	}
+
+	if e.opts.EnableDBStats && hasData {
+		dc := dbstatsCollector{
 			ctx:             ctx,
 			client:          client,
-			collections:     e.opts.CollStatsCollections,
-			compatibleMode:  e.opts.CompatibleMode,
+			databases:       e.opts.DBStatsDatabases,
 			discoveringMode: e.opts.DiscoveringMode,
 			logger:          e.opts.Logger,
 			topologyInfo:    topologyInfo,
 		}
-		registry.MustRegister(&cc)
+		registry.MustRegister(e.instrument(ctx, "dbstats", &dc))
 	}
 
-	if len(e.opts.IndexStatsCollections) > 0 {
-		ic := indexstatsCollector{
+	if e.opts.EnableProfileCollector && hasData {
+		pc := profileCollector{
 			ctx:             ctx,
 			client:          client,
-			collections:     e.opts.IndexStatsCollections,
+			databases:       e.opts.ProfileDatabases,
 			discoveringMode: e.opts.DiscoveringMode,
 			logger:          e.opts.Logger,
 			topologyInfo:    topologyInfo,
 		}
-		registry.MustRegister(&ic)
+		registry.MustRegister(e.instrument(ctx, "profile", &pc))
 	}
 
-	if !e.opts.DisableDiagnosticData {
-		ddc := diagnosticDataCollector{
+	if e.opts.EnableCollectionCounts && hasData {
+		ccc := collectionCountCollector{
+			ctx:             ctx,
+			client:          client,
+			databases:       e.opts.CollectionCountDatabases,
+			discoveringMode: e.opts.DiscoveringMode,
+			logger:          e.opts.Logger,
+			topologyInfo:    topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "collectioncount", &ccc))
+	}
+
+	if e.opts.EnableGridFSCollector && hasData {
+		gfc := gridfsCollector{
+			ctx:             ctx,
+			client:          client,
+			databases:       e.opts.GridFSDatabases,
+			discoveringMode: e.opts.DiscoveringMode,
+			logger:          e.opts.Logger,
+			topologyInfo:    topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "gridfs", &gfc))
+	}
+
+	if e.opts.EnablePlanCacheCollector && len(e.opts.PlanCacheCollections) > 0 && hasData {
+		pcc := planCacheCollector{
+			ctx:          ctx,
+			client:       client,
+			collections:  e.opts.PlanCacheCollections,
+			evictions:    e.planCacheEvict,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "plancache", &pcc))
+	}
+
+	if len(e.customQueries) > 0 && hasData {
+		cqc := customQueryCollector{
+			ctx:          ctx,
+			client:       client,
+			queries:      e.customQueries,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "customqueries", &cqc))
+	}
+
+	if e.opts.EnableCurrentOp {
+		coc := currentopCollector{
+			ctx:           ctx,
+			client:        client,
+			slowThreshold: e.opts.CurrentOpSlowThreshold,
+			logger:        e.opts.Logger,
+			topologyInfo:  topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "currentop", &coc))
+	}
+
+	if e.opts.EnableIndexBuildCollector {
+		ibc := indexBuildCollector{
+			ctx:          ctx,
+			client:       client,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "indexbuild", &ibc))
+	}
+
+	if e.opts.EnableWiredTigerCollector {
+		wtc := wiredTigerCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "wiredtiger", &wtc))
+	}
+
+	if e.opts.EnableCheckpointCollector {
+		cpc := checkpointCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "checkpoint", &cpc))
+	}
+
+	if e.opts.EnableQueryMetrics {
+		qmc := queryMetricsCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "querymetrics", &qmc))
+	}
+
+	if e.opts.EnableOperationMetrics {
+		omc := operationMetricsCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "operationmetrics", &omc))
+	}
+
+	if e.opts.EnableOpcounters {
+		occ := opcountersCollector{
 			ctx:            ctx,
-			client:         client,
-			compatibleMode: e.opts.CompatibleMode,
+			serverStatus:   serverStatus,
+			compatibleMode: e.opts.CompatibleMode && !e.opts.DisableDiagnosticData,
 			logger:         e.opts.Logger,
 			topologyInfo:   topologyInfo,
 		}
-		registry.MustRegister(&ddc)
+		registry.MustRegister(e.instrument(ctx, "opcounters", &occ))
+	}
+
+	if e.opts.EnableLockCollector {
+		lc := lockCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "lock", &lc))
+	}
+
+	if e.opts.EnableNetworkCollector {
+		nc := networkCollector{
+			ctx:            ctx,
+			serverStatus:   serverStatus,
+			compatibleMode: e.opts.CompatibleMode && !e.opts.DisableDiagnosticData,
+			logger:         e.opts.Logger,
+			topologyInfo:   topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "network", &nc))
+	}
+
+	if e.opts.EnableMemoryCollector {
+		mc := memoryCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "memory", &mc))
+	}
+
+	if e.opts.EnableAssertsCollector {
+		ac := assertsCollector{
+			ctx:            ctx,
+			serverStatus:   serverStatus,
+			compatibleMode: e.opts.CompatibleMode && !e.opts.DisableDiagnosticData,
+			logger:         e.opts.Logger,
+			topologyInfo:   topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "asserts", &ac))
+	}
+
+	if e.opts.EnableCursorCollector {
+		cc := cursorCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "cursor", &cc))
+	}
+
+	// The balancer state is only available through a mongos.
+	if e.opts.EnableBalancerCollector && nodeType == typeMongos {
+		bc := balancerCollector{
+			ctx:          ctx,
+			client:       client,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "balancer", &bc))
+	}
+
+	if e.opts.EnableChunksCollector && nodeType == typeMongos {
+		chc := chunksCollector{
+			ctx:                  ctx,
+			client:               client,
+			logger:               e.opts.Logger,
+			topologyInfo:         topologyInfo,
+			splitNamespaceLabels: e.opts.SplitNamespaceLabels,
+		}
+		registry.MustRegister(e.instrument(ctx, "chunks", &chc))
+	}
+
+	if e.opts.EnableConfigServerCollector && nodeType == typeMongos && e.configServerClients != nil {
+		csc := configServerCollector{
+			ctx:          ctx,
+			client:       client,
+			opts:         e.opts,
+			clients:      e.configServerClients,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "configservers", &csc))
+	}
+
+	// top doesn't work through mongos.
+	if e.opts.EnableTopCollector && nodeType != typeMongos {
+		tc := topCollector{
+			ctx:                  ctx,
+			client:               client,
+			logger:               e.opts.Logger,
+			topologyInfo:         topologyInfo,
+			splitNamespaceLabels: e.opts.SplitNamespaceLabels,
+		}
+		registry.MustRegister(e.instrument(ctx, "top", &tc))
+	}
+
+	// The TTL monitor runs only in mongod.
+	if e.opts.EnableTTLCollector && nodeType != typeMongos {
+		ttlc := ttlCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "ttl", &ttlc))
+	}
+
+	// Flow control runs only in mongod.
+	if e.opts.EnableFlowControlCollector && nodeType != typeMongos {
+		fcc := flowControlCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "flowcontrol", &fcc))
+	}
+
+	// The mongos transactions section has other counters.
+	if e.opts.EnableTransactionsCollector && nodeType != typeMongos {
+		tc := transactionsCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "transactions", &tc))
+	}
+
+	// There is no replication in mongos.
+	if e.opts.EnableReplBufferCollector && nodeType != typeMongos {
+		rbc := replBufferCollector{
+			ctx:          ctx,
+			serverStatus: serverStatus,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "replbuffer", &rbc))
+	}
+
+	// There is no oplog in mongos nor in arbiters.
+	if e.opts.EnableOplogCollector && nodeType != typeMongos && hasData {
+		oc := oplogCollector{
+			ctx:          ctx,
+			client:       client,
+			logger:       e.opts.Logger,
+			topologyInfo: topologyInfo,
+		}
+		registry.MustRegister(e.instrument(ctx, "oplog", &oc))
 	}
 
 	// replSetGetStatus is not supported through mongos
@@ -153,60 +886,68 @@ func (e *Exporter) makeRegistry(ctx context.Context, client *mongo.Client, topol
 			ctx:            ctx,
 			client:         client,
 			compatibleMode: e.opts.CompatibleMode,
+			remaps:         remaps,
+			localNodeOnly:  e.opts.LocalNodeOnly,
 			logger:         e.opts.Logger,
 			topologyInfo:   topologyInfo,
 		}
-		registry.MustRegister(&rsgsc)
+		registry.MustRegister(e.instrument(ctx, "replicasetstatus", &rsgsc))
 	}
 
 	return registry
 }
 
+// instrument wraps the collector to expose its scrape duration and success and, if the collector
+// timeout is set, to limit the time of each collection.
+func (e *Exporter) instrument(ctx context.Context, name string, c prometheus.Collector) prometheus.Collector {
+	ic := newInstrumentedCollector(name, c, e.logger)
+
+	timeout := e.opts.CollectorTimeout
+	if t, ok := e.opts.CollectorTimeouts[name]; ok {
+		timeout = t
+	}
+
+	if timeout > 0 {
+		ic.withTimeout(ctx, timeout, e.collectorTimeouts)
+	}
+
+	// Only the pedantic registry checks the metrics against their descriptions.
+	if e.opts.PedanticRegistry {
+		ic.withDescriptions()
+	}
+
+	return ic
+}
+
 func (e *Exporter) handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		e.scrapesInProgress.Inc()
+		defer func() {
+			e.scrapesInProgress.Dec()
+			e.lastScrape.SetToCurrentTime()
+		}()
 
-		client := e.client
-		topologyInfo := e.topologyInfo
-		// Use per-request connection.
-		if !e.opts.GlobalConnPool {
-			var err error
-			client, err = connect(ctx, e.opts.URI, e.opts.DirectConnect)
-			if err != nil {
-				e.logger.Errorf("Cannot connect to MongoDB: %v", err)
-				http.Error(
-					w,
-					"An error has occurred while connecting to MongoDB:\n\n"+err.Error(),
-					http.StatusInternalServerError,
-				)
-
-				return
-			}
+		ctx := r.Context()
 
-			defer func() {
-				if err = client.Disconnect(ctx); err != nil {
-					e.logger.Errorf("Cannot disconnect mongo client: %v", err)
-				}
-			}()
+		client, topologyInfo, release, err := e.scrapeClient(ctx)
+		if err != nil {
+			e.logger.Error(err)
+			http.Error(
+				w,
+				"An error has occurred while connecting to MongoDB:\n\n"+err.Error(),
+				http.StatusInternalServerError,
+			)
 
-			topologyInfo, err = newTopologyInfo(ctx, client)
-			if err != nil {
-				e.logger.Errorf("Cannot get topology info: %v", err)
-				http.Error(
-					w,
-					"An error has occurred while getting topology info:\n\n"+err.Error(),
-					http.StatusInternalServerError,
-				)
-
-				return
-			}
+			return
 		}
 
+		defer release()
+
 		registry := e.makeRegistry(ctx, client, topologyInfo)
 
 		gatherers := prometheus.Gatherers{}
 		gatherers = append(gatherers, prometheus.DefaultGatherer)
-		gatherers = append(gatherers, registry)
+		gatherers = append(gatherers, e.gatherer(registry))
 
 		// Delegate http serving to Prometheus client library, which will call collector.Collect.
 		h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{
@@ -218,25 +959,596 @@ func (e *Exporter) handler() http.Handler {
 	})
 }
 
+// scrapeClient returns the client and the topology info for a scrape, and a function to call when
+// the scrape is done with the client: the global client, reconnected if needed, a client from the
+// client cache, or a new client.
+func (e *Exporter) scrapeClient(ctx context.Context) (*mongo.Client, labelsGetter, func(), error) {
+	if e.opts.GlobalConnPool {
+		client, topologyInfo := e.globalClient()
+		if e.opts.ReconnectOnFailure {
+			var err error
+			client, topologyInfo, err = e.checkGlobalClient(ctx)
+			if err != nil {
+				return nil, nil, nil, errors.Wrap(err, "cannot reconnect to MongoDB")
+			}
+		}
+
+		// Shutdown clears the global client.
+		if client == nil {
+			return nil, nil, nil, errExporterClosed
+		}
+
+		return client, topologyInfo, func() {}, nil
+	}
+
+	if e.isClosed() {
+		return nil, nil, nil, errExporterClosed
+	}
+
+	// Reuse the connection from the previous scrapes, if it has not been idle for too long.
+	if e.clientCache != nil {
+		client, topologyInfo, release, err := e.clientCache.get(ctx, e.opts.URI, e.opts)
+		if err != nil {
+			return nil, nil, nil, errors.Wrap(err, "cannot connect to MongoDB")
+		}
+
+		return client, topologyInfo, release, nil
+	}
+
+	// Use per-request connection.
+	client, err := connect(ctx, e.opts.URI, e.opts)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "cannot connect to MongoDB")
+	}
+
+	release := func() {
+		if err := client.Disconnect(ctx); err != nil {
+			e.logger.Errorf("Cannot disconnect mongo client: %v", err)
+		}
+	}
+
+	topologyInfo, err := newTopologyInfo(ctx, client)
+	if err != nil {
+		release()
+
+		return nil, nil, nil, errors.Wrap(err, "cannot get topology info")
+	}
+
+	return client, topologyInfo, release, nil
+}
+
+// registerDefault registers the collector in the default registry. If there is an equal collector
+// already, from another Exporter in the same process, it returns that one.
+func registerDefault(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+	}
+
+	return c
+}
+
+func (e *Exporter) isClosed() bool {
+	e.clientMu.Lock()
+	defer e.clientMu.Unlock()
+
+	return e.closed
+}
+
+func (e *Exporter) globalClient() (*mongo.Client, labelsGetter) {
+	e.clientMu.Lock()
+	defer e.clientMu.Unlock()
+
+	return e.client, e.topologyInfo
+}
+
+// checkGlobalClient pings the server with the global client and, if it fails, replaces the client
+// and the topology info with new ones. The old client is kept if the reconnection fails too, so
+// the driver can still recover it. The lock is not held while pinging and connecting, so the
+// readiness probe and Shutdown don't wait for a dead server.
+func (e *Exporter) checkGlobalClient(ctx context.Context) (*mongo.Client, labelsGetter, error) {
+	old, oldTopologyInfo := e.globalClient()
+	if old == nil {
+		return nil, nil, errExporterClosed
+	}
+
+	err := old.Ping(ctx, nil)
+	if err == nil {
+		return old, oldTopologyInfo, nil
+	}
+
+	e.logger.Warnf("Cannot ping MongoDB, reconnecting: %s", err)
+
+	client, err := connect(ctx, e.opts.URI, e.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	topologyInfo, err := newTopologyInfo(ctx, client)
+	if err != nil {
+		if derr := client.Disconnect(ctx); derr != nil {
+			e.logger.Errorf("Cannot disconnect mongo client: %v", derr)
+		}
+
+		return nil, nil, errors.Wrap(err, "cannot get topology info")
+	}
+
+	e.clientMu.Lock()
+
+	// Shutdown, or another scrape reconnecting at the same time, might have replaced the client.
+	if e.client != old {
+		current, currentTopologyInfo := e.client, e.topologyInfo
+		e.clientMu.Unlock()
+
+		if err := client.Disconnect(ctx); err != nil {
+			e.logger.Errorf("Cannot disconnect mongo client: %v", err)
+		}
+
+		if current == nil {
+			return nil, nil, errExporterClosed
+		}
+
+		return current, currentTopologyInfo, nil
+	}
+
+	e.client = client
+	e.topologyInfo = topologyInfo
+	e.clientMu.Unlock()
+
+	if err := old.Disconnect(ctx); err != nil {
+		e.logger.Warnf("Cannot disconnect the old mongo client: %v", err)
+	}
+
+	return client, topologyInfo, nil
+}
+
+// gatherer returns the registry gatherer, filtering the metrics by name and renaming them if there
+// is a custom prefix.
+func (e *Exporter) gatherer(registry *prometheus.Registry) prometheus.Gatherer {
+	var g prometheus.Gatherer = registry
+
+	if e.metricAllow != nil || e.metricDeny != nil {
+		g = &filterGatherer{gatherer: g, allow: e.metricAllow, deny: e.metricDeny}
+	}
+
+	if e.opts.MetricsPrefix == "" || e.opts.MetricsPrefix == defaultMetricsPrefix {
+		return g
+	}
+
+	return &prefixGatherer{gatherer: g, prefix: e.opts.MetricsPrefix}
+}
+
 // Run starts the exporter.
 func (e *Exporter) Run() {
-	handler := e.handler()
-	exporter_shared.RunServer("MongoDB", e.webListenAddress, e.path, handler)
+	mux, err := e.serveMux()
+	if err != nil {
+		e.logger.Fatal(err)
+	}
+
+	tlsConfig, err := webTLSConfig(e.opts)
+	if err != nil {
+		e.logger.Fatal(err)
+	}
+
+	srv := &http.Server{
+		Addr:      e.webListenAddress,
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+
+	if e.opts.ReloadCollections != nil {
+		go e.reloadOnSignal()
+	}
+
+	if e.opts.PushGatewayURL != "" {
+		go e.pushLoop()
+	}
+
+	if tlsConfig != nil {
+		srv.Handler = hstsHandler(mux)
+
+		// The certificate and the key are already in the TLS config.
+		e.logger.Infof("Starting HTTPS server for https://%s%s ...", e.webListenAddress, e.path)
+		e.logger.Fatal(srv.ListenAndServeTLS("", ""))
+	}
+
+	e.logger.Infof("Starting HTTP server for http://%s%s ...", e.webListenAddress, e.path)
+	e.logger.Fatal(srv.ListenAndServe())
 }
 
-func connect(ctx context.Context, dsn string, directConnect bool) (*mongo.Client, error) {
-	clientOpts := options.Client().ApplyURI(dsn)
-	clientOpts.SetDirect(directConnect)
-	clientOpts.SetAppName("mongodb_exporter")
+// reloadOnSignal reloads the collection lists on every SIGHUP. The scrapes in progress keep the
+// old lists, the next ones use the new lists and run the discovery again.
+func (e *Exporter) reloadOnSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
 
-	client, err := mongo.Connect(ctx, clientOpts)
+	for range ch {
+		if err := e.reloadCollections(); err != nil {
+			e.logger.Errorf("Cannot reload the collection lists: %s", err)
+			continue
+		}
+
+		e.logger.Info("Collection lists reloaded")
+	}
+}
+
+func (e *Exporter) reloadCollections() error {
+	collStats, indexStats, err := e.opts.ReloadCollections()
+	if err != nil {
+		return err
+	}
+
+	lists, err := newCollectionLists(collStats, indexStats)
+	if err != nil {
+		return err
+	}
+
+	e.clientMu.Lock()
+	defer e.clientMu.Unlock()
+
+	e.collections = lists
+
+	return nil
+}
+
+func (e *Exporter) collectionLists() *collectionLists {
+	e.clientMu.Lock()
+	defer e.clientMu.Unlock()
+
+	return e.collections
+}
+
+// collectionLists are the collections to get $collStats and $indexStats from. They are never
+// modified, a reload replaces them.
+type collectionLists struct {
+	collStats         []string
+	collStatsPatterns []namespacePattern
+	indexStats        []string
+}
+
+func newCollectionLists(collStats, indexStats []string) (*collectionLists, error) {
+	collections, patterns, err := parseCollStatsCollections(collStats)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = client.Ping(ctx, nil); err != nil {
+	return &collectionLists{
+		collStats:         collections,
+		collStatsPatterns: patterns,
+		indexStats:        indexStats,
+	}, nil
+}
+
+// Shutdown releases the resources held by the exporter, disconnecting the global client if any.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.clientMu.Lock()
+	defer e.clientMu.Unlock()
+
+	if !e.closed && e.done != nil {
+		close(e.done)
+	}
+
+	e.closed = true
+
+	if e.clientCache != nil {
+		e.clientCache.close()
+	}
+
+	if e.configServerClients != nil {
+		e.configServerClients.close()
+	}
+
+	if e.client == nil {
+		return nil
+	}
+
+	if err := e.client.Disconnect(ctx); err != nil {
+		return errors.Wrap(err, "cannot disconnect mongo client")
+	}
+
+	e.client = nil
+
+	return nil
+}
+
+func connect(ctx context.Context, dsn string, opts *Opts) (*mongo.Client, error) {
+	clientOpts, err := clientOptions(dsn, opts)
+	if err != nil {
 		return nil, err
 	}
 
+	// The driver errors might include the URI, with the credentials.
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return nil, redactError(err, dsn)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, *clientOpts.ServerSelectionTimeout)
+	defer cancel()
+
+	if err = client.Ping(pingCtx, nil); err != nil {
+		// Stop the driver monitors of the unused client. The ping error is the one to report.
+		_ = client.Disconnect(ctx)
+
+		return nil, redactError(err, dsn)
+	}
+
 	return client, nil
 }
+
+// connectWithRetries retries the connection with an exponential backoff, to tolerate MongoDB starting
+// after the exporter.
+func connectWithRetries(ctx context.Context, dsn string, opts *Opts) (*mongo.Client, error) {
+	interval := opts.ConnectRetryInterval
+	if interval <= 0 {
+		interval = defaultConnectRetryInterval
+	}
+
+	for attempt := 0; ; attempt++ {
+		client, err := connect(ctx, dsn, opts)
+		if err == nil || attempt >= opts.ConnectRetries {
+			return client, err
+		}
+
+		opts.Logger.Warnf("Cannot connect to MongoDB (attempt %d of %d), retrying in %s: %s",
+			attempt+1, opts.ConnectRetries+1, interval, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+	}
+}
+
+func clientOptions(dsn string, opts *Opts) (*options.ClientOptions, error) {
+	clientOpts := options.Client().ApplyURI(dsn)
+	clientOpts.SetDirect(opts.DirectConnect)
+
+	if opts.AppName != "" {
+		clientOpts.SetAppName(opts.AppName)
+	} else {
+		clientOpts.SetAppName(defaultAppName)
+	}
+
+	// Explicit options take precedence over the URI. The defaults apply only if neither is set.
+	if opts.ConnectTimeout > 0 {
+		clientOpts.SetConnectTimeout(opts.ConnectTimeout)
+	} else if clientOpts.ConnectTimeout == nil {
+		clientOpts.SetConnectTimeout(defaultConnectTimeout)
+	}
+
+	if opts.ServerSelectionTimeout > 0 {
+		clientOpts.SetServerSelectionTimeout(opts.ServerSelectionTimeout)
+	} else if clientOpts.ServerSelectionTimeout == nil {
+		clientOpts.SetServerSelectionTimeout(defaultServerSelectionTimeout)
+	}
+
+	if opts.MaxPoolSize > 0 {
+		clientOpts.SetMaxPoolSize(opts.MaxPoolSize)
+	}
+
+	if opts.MinPoolSize > 0 {
+		clientOpts.SetMinPoolSize(opts.MinPoolSize)
+	}
+
+	if len(opts.Compressors) > 0 {
+		for _, c := range opts.Compressors {
+			if c != "zstd" && c != "snappy" && c != "zlib" {
+				return nil, errors.Errorf("invalid compressor %q, valid compressors: zstd, snappy, zlib", c)
+			}
+		}
+
+		clientOpts.SetCompressors(opts.Compressors)
+	}
+
+	if err := setCredential(clientOpts, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.ReadPreference != "" || len(opts.ReadPreferenceTags) > 0 {
+		rp, err := readPreference(clientOpts, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid read preference")
+		}
+
+		clientOpts.SetReadPreference(rp)
+	}
+
+	tlsConfig, err := mongoTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		clientOpts.SetTLSConfig(tlsConfig)
+	}
+
+	return clientOpts, nil
+}
+
+// readPreference returns the read preference with the mode and the tags from the options. Without
+// a mode in the options, the mode from the URI is used with the tags.
+func readPreference(clientOpts *options.ClientOptions, opts *Opts) (*readpref.ReadPref, error) {
+	mode := readpref.PrimaryMode
+	if opts.ReadPreference != "" {
+		var err error
+		if mode, err = readpref.ModeFromString(opts.ReadPreference); err != nil {
+			return nil, err
+		}
+	} else if clientOpts.ReadPreference != nil {
+		mode = clientOpts.ReadPreference.Mode()
+	}
+
+	if len(opts.ReadPreferenceTags) == 0 {
+		return readpref.New(mode)
+	}
+
+	if mode == readpref.PrimaryMode {
+		return nil, errors.New("read preference tags cannot be used with the primary mode")
+	}
+
+	tagSet := make(tag.Set, 0, len(opts.ReadPreferenceTags))
+
+	for _, t := range opts.ReadPreferenceTags {
+		i := strings.Index(t, ":")
+		if i <= 0 {
+			return nil, errors.Errorf("invalid read preference tag %q, it must be key:value", t)
+		}
+
+		tagSet = append(tagSet, tag.Tag{Name: t[:i], Value: t[i+1:]})
+	}
+
+	return readpref.New(mode, readpref.WithTagSets(tagSet))
+}
+
+// setCredential overrides the credentials from the URI with the authentication options. The username
+// and password files are read on each new connection so, the connections use the rotated secrets.
+func setCredential(clientOpts *options.ClientOptions, opts *Opts) error {
+	if opts.AuthMechanism == "" && opts.AuthSource == "" && opts.UsernameFile == "" && opts.PasswordFile == "" &&
+		opts.AWSSessionTokenFile == "" {
+		return nil
+	}
+
+	// Keep the credentials from the URI, if any.
+	var cred options.Credential
+	if clientOpts.Auth != nil {
+		cred = *clientOpts.Auth
+	}
+
+	if opts.AuthMechanism != "" {
+		cred.AuthMechanism = opts.AuthMechanism
+	}
+
+	if opts.AuthSource != "" {
+		cred.AuthSource = opts.AuthSource
+	}
+
+	if opts.UsernameFile != "" {
+		username, err := readSecretFile(opts.UsernameFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot read username file")
+		}
+
+		cred.Username = username
+	}
+
+	if opts.PasswordFile != "" {
+		password, err := readSecretFile(opts.PasswordFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot read password file")
+		}
+
+		cred.Password = password
+		cred.PasswordSet = true
+	}
+
+	if opts.AuthMechanism == authMechanismAWS {
+		if err := setAWSCredential(&cred, opts); err != nil {
+			return errors.Wrap(err, "invalid MONGODB-AWS credentials")
+		}
+	}
+
+	clientOpts.SetAuth(cred)
+
+	return nil
+}
+
+// setAWSCredential sets the session token and checks the AWS credentials. Without an access key ID,
+// the driver gets the credentials from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables or, if they are not set, from the ECS or EC2 instance metadata.
+func setAWSCredential(cred *options.Credential, opts *Opts) error {
+	switch opts.AuthSource {
+	case "":
+		// The URI default, admin, is not valid for this mechanism.
+		cred.AuthSource = "$external"
+	case "$external":
+	default:
+		return errors.Errorf("the authentication source must be $external, not %q", opts.AuthSource)
+	}
+
+	if opts.AWSSessionTokenFile != "" {
+		token, err := readSecretFile(opts.AWSSessionTokenFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot read AWS session token file")
+		}
+
+		props := make(map[string]string, len(cred.AuthMechanismProperties)+1)
+		for k, v := range cred.AuthMechanismProperties {
+			props[k] = v
+		}
+
+		props["AWS_SESSION_TOKEN"] = token
+		cred.AuthMechanismProperties = props
+	}
+
+	switch {
+	case cred.Username != "" && cred.Password == "":
+		return errors.New("the access key ID is set, but the secret access key (the password) is missing")
+	case cred.Username == "" && cred.Password != "":
+		return errors.New("the secret access key is set, but the access key ID (the username) is missing")
+	case cred.Username == "" && cred.AuthMechanismProperties["AWS_SESSION_TOKEN"] != "":
+		return errors.New("the session token is set, but the access key ID and the secret access key are missing")
+	case cred.Username == "" && (os.Getenv("AWS_ACCESS_KEY_ID") == "") != (os.Getenv("AWS_SECRET_ACCESS_KEY") == ""):
+		return errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+
+	return nil
+}
+
+// readSecretFile returns the file content without the surrounding whitespace, like the trailing
+// new line most editors add.
+func readSecretFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// mongoTLSConfig builds the TLS config for the MongoDB connection. It returns nil if no TLS
+// option was specified so the settings from the URI (if any) are kept.
+func mongoTLSConfig(opts *Opts) (*tls.Config, error) {
+	if opts.TLSCertificateKeyFile == "" && opts.TLSCAFile == "" && !opts.TLSInsecureSkipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: opts.TLSInsecureSkipVerify, //nolint:gosec
+	}
+
+	if opts.TLSCertificateKeyFile != "" {
+		// Like the mongo shell tlsCertificateKeyFile, the file has both the certificate and the key.
+		pem, err := ioutil.ReadFile(filepath.Clean(opts.TLSCertificateKeyFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read TLS certificate key file")
+		}
+
+		cert, err := tls.X509KeyPair(pem, pem)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load TLS certificate key file %s", opts.TLSCertificateKeyFile)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(filepath.Clean(opts.TLSCAFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read TLS CA file")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Wrapf(errInvalidCAFile, "TLS CA file %s", opts.TLSCAFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
@@ -0,0 +1,65 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// filterGatherer drops the gathered metric families not matching the allow regex, if set, or
+// matching the deny regex, if set. The deny regex takes precedence.
+type filterGatherer struct {
+	gatherer prometheus.Gatherer
+	allow    *regexp.Regexp
+	deny     *regexp.Regexp
+}
+
+func (g *filterGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	filtered := families[:0]
+
+	for _, family := range families {
+		name := family.GetName()
+		if g.allow != nil && !g.allow.MatchString(name) {
+			continue
+		}
+
+		if g.deny != nil && g.deny.MatchString(name) {
+			continue
+		}
+
+		filtered = append(filtered, family)
+	}
+
+	return filtered, err
+}
+
+// compileMetricNameRegex compiles a regex matching whole metric names. It returns nil for an
+// empty expression.
+func compileMetricNameRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+var _ prometheus.Gatherer = (*filterGatherer)(nil)
@@ -0,0 +1,69 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+		for _, name := range []string{"mongodb_up", "mongodb_ss_opcounters", "mongodb_ss_wt_cache_bytes", "mongodb_collector_scrape_time_ms"} {
+			d := prometheus.NewDesc(name, name, nil, nil)
+			ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, 1)
+		}
+	}})
+
+	allow, err := compileMetricNameRegex("mongodb_ss_.*|mongodb_up")
+	require.NoError(t, err)
+	deny, err := compileMetricNameRegex("mongodb_ss_wt_.*")
+	require.NoError(t, err)
+
+	want := strings.NewReader(`# HELP mongodb_ss_opcounters mongodb_ss_opcounters
+# TYPE mongodb_ss_opcounters gauge
+mongodb_ss_opcounters 1
+# HELP mongodb_up mongodb_up
+# TYPE mongodb_up gauge
+mongodb_up 1
+`)
+
+	err = testutil.GatherAndCompare(&filterGatherer{gatherer: registry, allow: allow, deny: deny}, want)
+	require.NoError(t, err)
+
+	// The regexes match whole names.
+	allow, err = compileMetricNameRegex("mongodb_ss")
+	require.NoError(t, err)
+
+	families, err := (&filterGatherer{gatherer: registry, allow: allow}).Gather()
+	require.NoError(t, err)
+	assert.Empty(t, families)
+}
+
+func TestNewInvalidMetricRegex(t *testing.T) {
+	_, err := New(&Opts{MetricAllowRegex: "mongodb_("})
+	assert.Error(t, err)
+
+	_, err = New(&Opts{MetricDenyRegex: "mongodb_("})
+	assert.Error(t, err)
+}
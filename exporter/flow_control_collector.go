@@ -0,0 +1,87 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// flowControlCollector exposes the flow control state from serverStatus().flowControl, available
+// since MongoDB 4.2. Flow control throttles the writes on the primary to limit the majority
+// committed lag. There is no flow control in mongos.
+type flowControlCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *flowControlCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *flowControlCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *flowControlCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range flowControlMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// flowControlMetrics returns no metrics for servers older than 4.2, without the flowControl section.
+func flowControlMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"flowControl", "isLagged"},
+			name: "mongodb_flow_control_is_lagged",
+			help: "Whether the majority committed lag is greater than the flow control threshold",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"flowControl", "timeAcquiringMicros"},
+			name: "mongodb_flow_control_time_acquiring_micros_total",
+			help: "Total time the operations have waited to acquire a flow control ticket, in microseconds",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"flowControl", "targetRateLimit"},
+			name: "mongodb_flow_control_targetRateLimit",
+			help: "Maximum number of locks per second that the writes can acquire on the primary",
+			vt:   prometheus.GaugeValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*flowControlCollector)(nil)
@@ -0,0 +1,58 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFlowControlMetrics(t *testing.T) {
+	m := bson.M{
+		"flowControl": bson.M{
+			"enabled":             true,
+			"targetRateLimit":     int32(1000000000),
+			"timeAcquiringMicros": int64(1523),
+			"locksPerKiloOp":      float64(0),
+			"sustainerRate":       int32(0),
+			"isLagged":            false,
+			"isLaggedCount":       int32(0),
+			"isLaggedTimeMicros":  int64(0),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_flow_control_is_lagged Whether the majority committed lag is greater than the flow control threshold",
+		"# TYPE mongodb_flow_control_is_lagged gauge",
+		`mongodb_flow_control_is_lagged{rs_nm="rs1"} 0`,
+		"# HELP mongodb_flow_control_targetRateLimit Maximum number of locks per second that the writes can acquire on the primary",
+		"# TYPE mongodb_flow_control_targetRateLimit gauge",
+		`mongodb_flow_control_targetRateLimit{rs_nm="rs1"} 1e+09`,
+		"# HELP mongodb_flow_control_time_acquiring_micros_total Total time the operations have waited to acquire a flow control ticket, in microseconds",
+		"# TYPE mongodb_flow_control_time_acquiring_micros_total counter",
+		`mongodb_flow_control_time_acquiring_micros_total{rs_nm="rs1"} 1523`,
+	}
+
+	metrics := flowControlMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Servers before 4.2 have no flowControl section.
+	assert.Empty(t, flowControlMetrics(bson.M{"ok": float64(1)}, nil))
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
@@ -28,17 +29,53 @@ import (
 // This collector is always enabled and it is not directly related to any particular MongoDB
 // command to gather stats.
 type generalCollector struct {
-	ctx    context.Context
-	client *mongo.Client
-	logger *logrus.Logger
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+	// The storage engine comes from the serverStatus shared with the other collectors.
+	serverStatus *scrapeServerStatus
 }
 
 func (d *generalCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
+func (d *generalCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
 func (d *generalCollector) Collect(ch chan<- prometheus.Metric) {
 	ch <- mongodbUpMetric(d.ctx, d.client, d.logger)
+
+	// buildInfo is not cached: the server can be upgraded while the client reconnects to it.
+	buildInfo, err := getBuildInfo(d.ctx, d.client)
+	if err != nil {
+		d.logger.Errorf("cannot get buildInfo: %s", err)
+		d.fail()
+
+		return
+	}
+
+	serverStatus, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	// mongos has no storage engine.
+	storageEngine, _ := walkTo(serverStatus, []string{"storageEngine", "name"}).(string)
+
+	labels := map[string]string{}
+	if d.topologyInfo != nil {
+		labels = d.topologyInfo.baseLabels()
+	}
+
+	ch <- versionInfoMetric(buildInfo, storageEngine, labels)
 }
 
 func mongodbUpMetric(ctx context.Context, client *mongo.Client, log *logrus.Logger) prometheus.Metric {
@@ -55,4 +92,28 @@ func mongodbUpMetric(ctx context.Context, client *mongo.Client, log *logrus.Logg
 	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, value)
 }
 
+// versionInfoMetric exposes the server version and storage engine as labels. The storage engine
+// is empty in mongos. It's not named mongodb_version_info, the compatible mode metric with only
+// the mongodb label.
+func versionInfoMetric(buildInfo bson.M, storageEngine string, labels map[string]string) prometheus.Metric {
+	labels["version"], _ = buildInfo["version"].(string)
+	labels["git_version"], _ = buildInfo["gitVersion"].(string)
+	labels["storage_engine"] = storageEngine
+
+	d := prometheus.NewDesc("mongodb_server_version_info", "The server version, git version and storage engine.", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, 1)
+}
+
+func getBuildInfo(ctx context.Context, client *mongo.Client) (bson.M, error) {
+	var m bson.M
+
+	cmd := bson.D{{Key: "buildInfo", Value: 1}}
+	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
 var _ prometheus.Collector = (*generalCollector)(nil)
@@ -18,15 +18,18 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/percona/exporter_shared/helpers"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 
 	"github.com/percona/mongodb_exporter/internal/tu"
 )
@@ -36,20 +39,35 @@ func TestGeneralCollector(t *testing.T) {
 	defer cancel()
 
 	client := tu.DefaultTestClient(ctx, t)
+
+	ti, err := newTopologyInfo(ctx, client)
+	require.NoError(t, err)
+
 	c := &generalCollector{
-		ctx:    ctx,
-		client: client,
-		logger: logrus.New(),
+		ctx:          ctx,
+		client:       client,
+		logger:       logrus.New(),
+		topologyInfo: ti,
+		serverStatus: newScrapeServerStatus(client),
 	}
 
+	buildInfo, err := getBuildInfo(ctx, client)
+	require.NoError(t, err)
+
+	serverStatus, err := getServerStatus(ctx, client)
+	require.NoError(t, err)
+
 	// The last \n at the end of this string is important
-	expected := strings.NewReader(`
+	expected := strings.NewReader(fmt.Sprintf(`
+# HELP mongodb_server_version_info The server version, git version and storage engine.
+# TYPE mongodb_server_version_info gauge
+mongodb_server_version_info{git_version="%s",storage_engine="%s",version="%s"} 1
 # HELP mongodb_up Whether MongoDB is up.
 # TYPE mongodb_up gauge
-mongodb_up 1` + "\n")
+mongodb_up 1`, buildInfo["gitVersion"], walkTo(serverStatus, []string{"storageEngine", "name"}), buildInfo["version"]) + "\n")
 
 	reg := prometheus.NewPedanticRegistry()
-	err := reg.Register(c)
+	err = reg.Register(c)
 	require.NoError(t, err)
 
 	err = testutil.GatherAndCompare(reg, expected)
@@ -57,6 +75,7 @@ mongodb_up 1` + "\n")
 
 	assert.NoError(t, client.Disconnect(ctx))
 
+	// Without a connection, there is no version.
 	expected = strings.NewReader(`
 # HELP mongodb_up Whether MongoDB is up.
 # TYPE mongodb_up gauge
@@ -64,3 +83,75 @@ mongodb_up 0` + "\n")
 	err = testutil.GatherAndCompare(reg, expected)
 	assert.NoError(t, err)
 }
+
+func TestGeneralCollectorCompatibleMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	client := tu.DefaultTestClient(ctx, t)
+
+	ti, err := newTopologyInfo(ctx, client)
+	require.NoError(t, err)
+
+	// The compatible mode metrics of getDiagnosticData must not clash with the general ones.
+	reg := prometheus.NewRegistry()
+	err = reg.Register(&generalCollector{
+		ctx:          ctx,
+		client:       client,
+		logger:       logrus.New(),
+		topologyInfo: ti,
+		serverStatus: newScrapeServerStatus(client),
+	})
+	require.NoError(t, err)
+
+	err = reg.Register(&diagnosticDataCollector{
+		ctx:            ctx,
+		client:         client,
+		compatibleMode: true,
+		logger:         logrus.New(),
+		topologyInfo:   ti,
+	})
+	require.NoError(t, err)
+
+	_, err = reg.Gather()
+	assert.NoError(t, err)
+}
+
+func TestVersionInfoMetric(t *testing.T) {
+	buildInfo := bson.M{"version": "4.4.6", "gitVersion": "72e66213c2c3eab37d9358d5e78ad7f5c1d0d0d7"}
+
+	want := []string{
+		"# HELP mongodb_server_version_info The server version, git version and storage engine.",
+		"# TYPE mongodb_server_version_info gauge",
+		`mongodb_server_version_info{git_version="72e66213c2c3eab37d9358d5e78ad7f5c1d0d0d7",rs_nm="rs1",storage_engine="wiredTiger",version="4.4.6"} 1`,
+	}
+
+	metric := versionInfoMetric(buildInfo, "wiredTiger", map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format([]prometheus.Metric{metric}))
+
+	// mongos has no storage engine.
+	want = []string{
+		"# HELP mongodb_server_version_info The server version, git version and storage engine.",
+		"# TYPE mongodb_server_version_info gauge",
+		`mongodb_server_version_info{git_version="72e66213c2c3eab37d9358d5e78ad7f5c1d0d0d7",storage_engine="",version="4.4.6"} 1`,
+	}
+
+	metric = versionInfoMetric(buildInfo, "", map[string]string{})
+	assert.Equal(t, want, helpers.Format([]prometheus.Metric{metric}))
+}
+
+func TestVersionInfoMetricCompatibleMode(t *testing.T) {
+	buildInfo := bson.M{"version": "4.4.6", "gitVersion": "72e66213c2c3eab37d9358d5e78ad7f5c1d0d0d7"}
+	serverStatus := bson.M{"version": "4.4.6", "storageEngine": bson.M{"name": "wiredTiger"}}
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(&fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+		ch <- versionInfoMetric(buildInfo, "wiredTiger", map[string]string{})
+	}}))
+	require.NoError(t, reg.Register(&fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+		ch <- serverVersion(bson.M{"serverStatus": serverStatus})
+	}}))
+
+	_, err := reg.Gather()
+	assert.NoError(t, err)
+}
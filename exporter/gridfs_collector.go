@@ -0,0 +1,145 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// gridfsCollector exposes the number of files and the size of the chunks of the GridFS buckets.
+// A bucket is a pair of <bucket>.files and <bucket>.chunks collections, fs being the default bucket.
+type gridfsCollector struct {
+	collectFailure
+
+	ctx             context.Context
+	client          *mongo.Client
+	databases       []string
+	discoveringMode bool
+	logger          *logrus.Logger
+	topologyInfo    labelsGetter
+}
+
+func (d *gridfsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *gridfsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *gridfsCollector) Collect(ch chan<- prometheus.Metric) {
+	databases := d.databases
+
+	if len(databases) == 0 && d.discoveringMode {
+		dbNames, err := d.client.ListDatabaseNames(d.ctx, bson.D{})
+		if err != nil {
+			d.logger.Errorf("cannot get the database names list: %s", err)
+			d.fail()
+
+			return
+		}
+
+		databases = filterSystemDatabases(dbNames)
+	}
+
+	for _, database := range databases {
+		if database == "" {
+			continue
+		}
+
+		db := d.client.Database(database)
+
+		collections, err := db.ListCollectionNames(d.ctx, bson.D{{Key: "type", Value: "collection"}})
+		if err != nil {
+			d.logger.Errorf("cannot list the collections in database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		for _, bucket := range gridFSBuckets(collections) {
+			files, err := db.Collection(bucket + ".files").EstimatedDocumentCount(d.ctx)
+			if err != nil {
+				d.logger.Errorf("cannot count the files of the GridFS bucket %s.%s: %s", database, bucket, err)
+				d.fail()
+
+				continue
+			}
+
+			var stats bson.M
+
+			cmd := bson.D{{Key: "collStats", Value: bucket + ".chunks"}, {Key: "scale", Value: 1}}
+			if err := db.RunCommand(d.ctx, cmd).Decode(&stats); err != nil {
+				d.logger.Errorf("cannot get collStats of the GridFS bucket %s.%s: %s", database, bucket, err)
+				d.fail()
+
+				continue
+			}
+
+			for _, metric := range gridFSMetrics(database, bucket, files, stats, d.topologyInfo.baseLabels()) {
+				ch <- metric
+			}
+		}
+	}
+}
+
+// gridFSBuckets returns the names of the buckets with both the files and the chunks collections,
+// sorted by name.
+func gridFSBuckets(collections []string) []string {
+	names := make(map[string]bool, len(collections))
+	for _, c := range collections {
+		names[c] = true
+	}
+
+	var buckets []string
+
+	for _, c := range collections {
+		bucket := strings.TrimSuffix(c, ".files")
+		if bucket != c && bucket != "" && names[bucket+".chunks"] {
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	sort.Strings(buckets)
+
+	return buckets
+}
+
+// gridFSMetrics returns the number of files of the bucket and the size of its chunks collection.
+func gridFSMetrics(database, bucket string, files int64, chunksStats bson.M, labels map[string]string) []prometheus.Metric {
+	labels["db"] = database
+	labels["bucket"] = bucket
+
+	d := prometheus.NewDesc("mongodb_gridfs_files_count", "Estimated number of files in the GridFS bucket", nil, labels)
+	metrics := []prometheus.Metric{prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(files))}
+
+	if size, err := asFloat64(chunksStats["size"]); err == nil && size != nil {
+		d := prometheus.NewDesc("mongodb_gridfs_bytes", "Uncompressed size of the chunks of the GridFS bucket", nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *size))
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*gridfsCollector)(nil)
@@ -0,0 +1,48 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGridFSBuckets(t *testing.T) {
+	collections := []string{"users", "fs.files", "fs.chunks", "images.chunks", "images.files", "docs.files", ".files"}
+
+	assert.Equal(t, []string{"fs", "images"}, gridFSBuckets(collections))
+	assert.Empty(t, gridFSBuckets([]string{"users"}))
+}
+
+func TestGridFSMetrics(t *testing.T) {
+	stats := bson.M{"ns": "testdb.images.chunks", "size": int64(4194304), "count": int32(16), "storageSize": int64(1048576)}
+
+	want := []string{
+		"# HELP mongodb_gridfs_bytes Uncompressed size of the chunks of the GridFS bucket",
+		"# TYPE mongodb_gridfs_bytes gauge",
+		`mongodb_gridfs_bytes{bucket="images",db="testdb",rs_nm="rs1"} 4.194304e+06`,
+		"# HELP mongodb_gridfs_files_count Estimated number of files in the GridFS bucket",
+		"# TYPE mongodb_gridfs_files_count gauge",
+		`mongodb_gridfs_files_count{bucket="images",db="testdb",rs_nm="rs1"} 4`,
+	}
+
+	metrics := gridFSMetrics("testdb", "images", 4, stats, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
@@ -0,0 +1,194 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// indexBuildCollector exposes the progress of the index builds from currentOp. There are only
+// series for the builds in progress, so the cardinality is bounded by the running builds.
+type indexBuildCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *indexBuildCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *indexBuildCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *indexBuildCollector) Collect(ch chan<- prometheus.Metric) {
+	var m bson.M
+
+	cmd := bson.D{
+		{Key: "currentOp", Value: 1},
+		{Key: "$or", Value: bson.A{
+			bson.M{"command.createIndexes": bson.M{"$exists": true}},
+			bson.M{"msg": primitive.Regex{Pattern: "^Index Build"}},
+		}},
+	}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&m); err != nil {
+		d.logger.Errorf("cannot run currentOp: %s", err)
+		d.fail()
+
+		return
+	}
+
+	inprog, ok := m["inprog"].(primitive.A)
+	if !ok {
+		d.logger.Errorf("cannot decode currentOp: %T for inprog field", m["inprog"])
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range indexBuildMetrics(inprog, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// indexBuildMetrics returns the number of index builds by namespace and, for the builds reporting
+// their progress, the ratio of the documents processed for each index being built. Since MongoDB 4.4,
+// the createIndexes command and the index builder thread are different operations of the same build,
+// so the builds are identified by their namespace and index names.
+func indexBuildMetrics(inprog primitive.A, labels map[string]string) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	builds := map[string]map[string]bool{}
+	reported := map[string]bool{}
+
+	for _, item := range inprog {
+		op, ok := item.(bson.M)
+		if !ok {
+			continue
+		}
+
+		ns := indexBuildNamespace(op)
+		if ns == "" {
+			continue
+		}
+
+		names := indexBuildNames(op)
+
+		if builds[ns] == nil {
+			builds[ns] = map[string]bool{}
+		}
+
+		builds[ns][strings.Join(names, ",")] = true
+
+		done, _ := asFloat64(walkTo(op, []string{"progress", "done"}))
+		total, _ := asFloat64(walkTo(op, []string{"progress", "total"}))
+
+		if done == nil || total == nil || *total <= 0 {
+			continue
+		}
+
+		for _, index := range names {
+			if reported[ns+"."+index] {
+				continue
+			}
+
+			reported[ns+"."+index] = true
+
+			indexLabels := make(map[string]string, len(labels)+2) //nolint:gomnd
+			for k, v := range labels {
+				indexLabels[k] = v
+			}
+
+			indexLabels["namespace"] = ns
+			indexLabels["index"] = index
+
+			d := prometheus.NewDesc("mongodb_index_build_progress_ratio",
+				"Ratio of the documents processed by the index build, from 0 to 1", nil, indexLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *done / *total))
+		}
+	}
+
+	namespaces := make([]string, 0, len(builds))
+	for ns := range builds {
+		namespaces = append(namespaces, ns)
+	}
+
+	sort.Strings(namespaces)
+
+	for _, ns := range namespaces {
+		nsLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			nsLabels[k] = v
+		}
+
+		nsLabels["namespace"] = ns
+
+		d := prometheus.NewDesc("mongodb_index_build_in_progress",
+			"Number of index builds in progress on the collection", nil, nsLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(len(builds[ns]))))
+	}
+
+	return metrics
+}
+
+// indexBuildNamespace returns the collection of the index build. The createIndexes command has
+// the db.$cmd namespace, while the index builder threads have the collection namespace.
+func indexBuildNamespace(op bson.M) string {
+	ns, _ := op["ns"].(string)
+
+	if coll, ok := walkTo(op, []string{"command", "createIndexes"}).(string); ok {
+		db := strings.SplitN(ns, ".", 2)[0] //nolint:gomnd
+		if db == "" {
+			db, _ = walkTo(op, []string{"command", "$db"}).(string)
+		}
+
+		return db + "." + coll
+	}
+
+	return ns
+}
+
+// indexBuildNames returns the names of the indexes in the createIndexes command of the operation.
+func indexBuildNames(op bson.M) []string {
+	indexes, _ := walkTo(op, []string{"command", "indexes"}).(primitive.A)
+
+	names := make([]string, 0, len(indexes))
+
+	for _, index := range indexes {
+		if im, ok := index.(bson.M); ok {
+			if name, ok := im["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+var _ prometheus.Collector = (*indexBuildCollector)(nil)
@@ -0,0 +1,74 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIndexBuildMetrics(t *testing.T) {
+	inprog := primitive.A{
+		// The createIndexes command waiting for the build, since MongoDB 4.4.
+		bson.M{
+			"ns": "testdb.$cmd",
+			"command": bson.M{
+				"createIndexes": "col1",
+				"indexes":       primitive.A{bson.M{"name": "a_1"}, bson.M{"name": "b_1"}},
+			},
+		},
+		// The index builder thread of the same build.
+		bson.M{
+			"ns":   "testdb.col1",
+			"desc": "IndexBuildsCoordinatorMongod-0",
+			"command": bson.M{
+				"createIndexes": "col1",
+				"indexes":       primitive.A{bson.M{"name": "a_1"}, bson.M{"name": "b_1"}},
+			},
+			"msg":      "Index Build: scanning collection Index Build: scanning collection: 250/1000 25%",
+			"progress": bson.M{"done": int64(250), "total": int64(1000)},
+		},
+		bson.M{
+			"ns": "testdb.col2",
+			"command": bson.M{
+				"createIndexes": "col2",
+				"indexes":       primitive.A{bson.M{"name": "c_1"}},
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_index_build_in_progress Number of index builds in progress on the collection",
+		"# TYPE mongodb_index_build_in_progress gauge",
+		`mongodb_index_build_in_progress{namespace="testdb.col1",rs_nm="rs1"} 1`,
+		`mongodb_index_build_in_progress{namespace="testdb.col2",rs_nm="rs1"} 1`,
+		"# HELP mongodb_index_build_progress_ratio Ratio of the documents processed by the index build, from 0 to 1",
+		"# TYPE mongodb_index_build_progress_ratio gauge",
+		`mongodb_index_build_progress_ratio{index="a_1",namespace="testdb.col1",rs_nm="rs1"} 0.25`,
+		`mongodb_index_build_progress_ratio{index="b_1",namespace="testdb.col1",rs_nm="rs1"} 0.25`,
+	}
+
+	metrics := indexBuildMetrics(inprog, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Without index builds, there are no series.
+	assert.Empty(t, indexBuildMetrics(primitive.A{}, nil))
+}
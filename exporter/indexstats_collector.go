@@ -19,39 +19,60 @@ package exporter
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type indexstatsCollector struct {
+	collectFailure
+
 	ctx             context.Context
 	client          *mongo.Client
 	collections     []string
 	discoveringMode bool
 	logger          *logrus.Logger
 	topologyInfo    labelsGetter
+	// In discovering mode, databases to get the collections from. If empty, the databases
+	// in collections are used.
+	databases []string
+	// In discovering mode, maximum number of collections per database. Zero means no limit.
+	maxCollectionsPerDB int
+	// Add the database and collection labels besides the namespace label.
+	splitNamespaceLabels bool
+	// Expose the index accesses as mongodb_indexstats_accesses_ops_total and
+	// mongodb_indexstats_accesses_since_seconds instead of a metric per index.
+	accessCounters bool
+	// In discovering mode, maximum number of collections across all the databases. Zero means no limit.
+	maxDiscovered int
+	// Discover the system.* collections too.
+	includeSystem bool
 }
 
 func (d *indexstatsCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
+func (d *indexstatsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
 func (d *indexstatsCollector) Collect(ch chan<- prometheus.Metric) {
 	if d.discoveringMode {
-		databases := map[string][]string{}
-		for _, dbCollection := range d.collections {
-			parts := strings.Split(dbCollection, ".")
-			if _, ok := databases[parts[0]]; !ok {
-				db := parts[0]
-				databases[db], _ = d.client.Database(parts[0]).ListCollectionNames(d.ctx, bson.D{})
-			}
-		}
+		d.collections = d.discoverCollections(ch)
 
-		d.collections = fromMapToSlice(databases)
+		if d.maxDiscovered > 0 {
+			var capped prometheus.Metric
+
+			d.collections, capped = capDiscoveredCollections(d.collections, d.maxDiscovered, "indexstats",
+				d.logger, d.topologyInfo.baseLabels())
+			ch <- capped
+		}
 	}
 	for _, dbCollection := range d.collections {
 		parts := strings.Split(dbCollection, ".")
@@ -69,12 +90,14 @@ func (d *indexstatsCollector) Collect(ch chan<- prometheus.Metric) {
 		cursor, err := d.client.Database(database).Collection(collection).Aggregate(d.ctx, mongo.Pipeline{aggregation})
 		if err != nil {
 			d.logger.Errorf("cannot get $indexStats cursor for collection %s.%s: %s", database, collection, err)
+			d.fail()
 			continue
 		}
 
 		var stats []bson.M
 		if err = cursor.All(d.ctx, &stats); err != nil {
 			d.logger.Errorf("cannot get $indexStats for collection %s.%s: %s", database, collection, err)
+			d.fail()
 			continue
 		}
 
@@ -86,17 +109,131 @@ func (d *indexstatsCollector) Collect(ch chan<- prometheus.Metric) {
 			// same, for different collections.
 			prefix := fmt.Sprintf("%s_%s_%s", database, collection, m["name"])
 			labels := d.topologyInfo.baseLabels()
-			labels["namespace"] = database + "." + collection
+			setNamespaceLabels(labels, database+"."+collection, d.splitNamespaceLabels)
 			labels["key_name"] = fmt.Sprintf("%s", m["name"])
 
+			if d.accessCounters {
+				for _, metric := range indexAccessMetrics(m, labels) {
+					ch <- metric
+				}
+
+				continue
+			}
+
 			metrics := sanitizeMetrics(m)
-			for _, metric := range makeMetrics(prefix, metrics, labels, false) {
+			for _, metric := range makeMetrics(prefix, metrics, labels, false, nil) {
 				ch <- metric
 			}
 		}
 	}
 }
 
+// discoverCollections returns all the collections in the databases to monitor, up to maxCollectionsPerDB
+// per database. If the limit is set, it also sends whether the list of each database was truncated.
+func (d *indexstatsCollector) discoverCollections(ch chan<- prometheus.Metric) []string {
+	databases := d.databases
+	if len(databases) == 0 {
+		seen := map[string]bool{}
+
+		for _, dbCollection := range d.collections {
+			db := strings.Split(dbCollection, ".")[0]
+			if !seen[db] {
+				seen[db] = true
+				databases = append(databases, db)
+			}
+		}
+	}
+
+	var collections []string
+
+	for _, db := range databases {
+		if db == "" {
+			continue
+		}
+
+		names, err := d.client.Database(db).ListCollectionNames(d.ctx, bson.D{})
+		if err != nil {
+			d.logger.Errorf("cannot list the collections in database %s: %s", db, err)
+			d.fail()
+			continue
+		}
+
+		// system.views fails $indexStats, it has no indexes.
+		names = filterSystemCollections(names, d.includeSystem, "system.views")
+
+		if d.maxCollectionsPerDB > 0 {
+			var truncated bool
+
+			names, truncated = limitCollections(names, d.maxCollectionsPerDB)
+			if truncated {
+				d.logger.Warnf("database %s has more than %d collections, only the first %d are used for $indexStats",
+					db, d.maxCollectionsPerDB, d.maxCollectionsPerDB)
+			}
+
+			ch <- indexStatsTruncatedMetric(db, truncated, d.topologyInfo.baseLabels())
+		}
+
+		for _, name := range names {
+			collections = append(collections, db+"."+name)
+		}
+	}
+
+	return collections
+}
+
+// limitCollections returns up to max collections, sorted by name so the same collections are
+// used on every scrape, and whether the list was truncated.
+func limitCollections(collections []string, max int) ([]string, bool) {
+	if len(collections) <= max {
+		return collections, false
+	}
+
+	sorted := make([]string, len(collections))
+	copy(sorted, collections)
+	sort.Strings(sorted)
+
+	return sorted[:max], true
+}
+
+// capDiscoveredCollections returns up to max of the discovered collections and a gauge telling
+// whether the collector has more collections than the limit.
+func capDiscoveredCollections(collections []string, max int, collector string, logger *logrus.Logger,
+	labels map[string]string) ([]string, prometheus.Metric) {
+	collections, capped := limitCollections(collections, max)
+	if capped {
+		logger.Warnf("more than %d collections were discovered for %s, only the first %d are used",
+			max, collector, max)
+	}
+
+	labels["collector"] = collector
+
+	value := 0.0
+	if capped {
+		value = 1
+	}
+
+	d := prometheus.NewDesc("mongodb_exporter_collections_capped",
+		"Whether the discovered collections were capped to the maximum number of discovered collections",
+		nil, labels)
+
+	return collections, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, value)
+}
+
+func indexStatsTruncatedMetric(database string, truncated bool, labels map[string]string) prometheus.Metric {
+	labels["database"] = database
+
+	value := 0.0
+	if truncated {
+		value = 1
+	}
+
+	d := prometheus.NewDesc("mongodb_indexstats_truncated",
+		"Whether the discovered collections of the database were truncated to the maximum number of collections per database",
+		nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, value)
+}
+
 // According to specs, we should expose only this 2 metrics. 'building' might not exist.
 func sanitizeMetrics(m bson.M) bson.M {
 	ops := float64(0)
@@ -122,4 +259,25 @@ func sanitizeMetrics(m bson.M) bson.M {
 	return filteredMetrics
 }
 
+// indexAccessMetrics builds the index accesses metrics from a $indexStats result. The counter is
+// reset when the server restarts or the index is rebuilt, at the since time.
+func indexAccessMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	if ops, err := asFloat64(walkTo(m, []string{"accesses", "ops"})); err == nil && ops != nil {
+		d := prometheus.NewDesc("mongodb_indexstats_accesses_ops_total",
+			"Number of operations that used the index since the accesses since time", nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.CounterValue, *ops))
+	}
+
+	if since, ok := walkTo(m, []string{"accesses", "since"}).(primitive.DateTime); ok {
+		d := prometheus.NewDesc("mongodb_indexstats_accesses_since_seconds",
+			"Time when the index accesses started to be counted, as a Unix timestamp", nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue,
+			float64(since.Time().Unix())))
+	}
+
+	return metrics
+}
+
 var _ prometheus.Collector = (*indexstatsCollector)(nil)
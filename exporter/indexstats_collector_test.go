@@ -24,6 +24,8 @@ import (
 	"time"
 
 	"github.com/AlekSi/pointer"
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -155,3 +157,71 @@ func TestSanitize(t *testing.T) {
 		assert.Equal(t, want, got)
 	})
 }
+
+func TestLimitCollections(t *testing.T) {
+	collections := []string{"c3", "c1", "c2"}
+
+	got, truncated := limitCollections(collections, 5)
+	assert.False(t, truncated)
+	assert.Equal(t, collections, got)
+
+	got, truncated = limitCollections(collections, 2)
+	assert.True(t, truncated)
+	assert.Equal(t, []string{"c1", "c2"}, got)
+	// The original list is not modified.
+	assert.Equal(t, []string{"c3", "c1", "c2"}, collections)
+
+	want := []string{
+		"# HELP mongodb_indexstats_truncated Whether the discovered collections of the database were truncated to the maximum number of collections per database",
+		"# TYPE mongodb_indexstats_truncated gauge",
+		`mongodb_indexstats_truncated{database="testdb",rs_nm="rs1"} 1`,
+	}
+
+	metric := indexStatsTruncatedMetric("testdb", truncated, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format([]prometheus.Metric{metric}))
+}
+
+func TestCapDiscoveredCollections(t *testing.T) {
+	collections := []string{"db2.c1", "db1.c2", "db1.c1"}
+
+	got, metric := capDiscoveredCollections(collections, 2, "collstats", logrus.New(),
+		map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, []string{"db1.c1", "db1.c2"}, got)
+
+	want := []string{
+		"# HELP mongodb_exporter_collections_capped Whether the discovered collections were capped to the maximum number of discovered collections",
+		"# TYPE mongodb_exporter_collections_capped gauge",
+		`mongodb_exporter_collections_capped{collector="collstats",rs_nm="rs1"} 1`,
+	}
+	assert.Equal(t, want, helpers.Format([]prometheus.Metric{metric}))
+
+	got, metric = capDiscoveredCollections(collections, 3, "indexstats", logrus.New(), map[string]string{})
+	assert.Equal(t, collections, got)
+	assert.Equal(t, `mongodb_exporter_collections_capped{collector="indexstats"} 0`,
+		helpers.Format([]prometheus.Metric{metric})[2])
+}
+
+func TestIndexAccessMetrics(t *testing.T) {
+	since := time.Date(2020, 8, 10, 19, 34, 52, 0, time.UTC)
+	m := bson.M{
+		"accesses": bson.M{
+			"ops":   int64(3),
+			"since": primitive.NewDateTimeFromTime(since),
+		},
+		"name": "idx_01",
+	}
+
+	labels := map[string]string{"namespace": "testdb.testcol_01", "key_name": "idx_01"}
+
+	want := []string{
+		"# HELP mongodb_indexstats_accesses_ops_total Number of operations that used the index since the accesses since time",
+		"# TYPE mongodb_indexstats_accesses_ops_total counter",
+		`mongodb_indexstats_accesses_ops_total{key_name="idx_01",namespace="testdb.testcol_01"} 3`,
+		"# HELP mongodb_indexstats_accesses_since_seconds Time when the index accesses started to be counted, as a Unix timestamp",
+		"# TYPE mongodb_indexstats_accesses_since_seconds gauge",
+		`mongodb_indexstats_accesses_since_seconds{key_name="idx_01",namespace="testdb.testcol_01"} 1.597088092e+09`,
+	}
+	assert.Equal(t, want, helpers.Format(indexAccessMetrics(m, labels)))
+
+	assert.Empty(t, indexAccessMetrics(bson.M{"name": "idx_01"}, labels))
+}
@@ -0,0 +1,249 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// instrumentedCollector wraps a collector to expose how long its Collect takes and whether
+// it succeeded. A collector fails if it sends an invalid metric, if it panics, if it times out or
+// if it reports a failure, like a failed command.
+type instrumentedCollector struct {
+	name         string
+	collector    prometheus.Collector
+	logger       *logrus.Logger
+	durationDesc *prometheus.Desc
+	successDesc  *prometheus.Desc
+
+	// If timeout is set, the collector runs with a context derived from ctx with this timeout
+	// and the collections cut off are counted in timeouts.
+	ctx      context.Context
+	timeout  time.Duration
+	timeouts *prometheus.CounterVec
+
+	// If checked is set, Describe collects the metrics to describe them and the next Collect sends
+	// them, so a pedantic registry can check them without running the collector twice.
+	checked bool
+	m       sync.Mutex
+	pending []prometheus.Metric
+}
+
+// contextCollector is a collector that can run with a context other than the one it was created with.
+type contextCollector interface {
+	prometheus.Collector
+	setContext(ctx context.Context)
+}
+
+// failureReporter is a collector that reports whether its last Collect failed. The collectors log
+// the errors, like the failed commands, instead of sending invalid metrics, and go on with the
+// other metrics, so the failures are not seen otherwise.
+type failureReporter interface {
+	collectFailed() bool
+}
+
+// collectFailure is embedded by the collectors to implement failureReporter.
+type collectFailure struct {
+	failed bool
+}
+
+// fail marks the collection as failed.
+func (f *collectFailure) fail() {
+	f.failed = true
+}
+
+func (f *collectFailure) collectFailed() bool {
+	return f.failed
+}
+
+func newInstrumentedCollector(name string, c prometheus.Collector, logger *logrus.Logger) *instrumentedCollector {
+	labels := prometheus.Labels{"collector": name}
+
+	return &instrumentedCollector{
+		name:      name,
+		collector: c,
+		logger:    logger,
+		durationDesc: prometheus.NewDesc("mongodb_collector_scrape_duration_seconds",
+			"Duration of the collector scrape", nil, labels),
+		successDesc: prometheus.NewDesc("mongodb_collector_success",
+			"Whether the collector succeeded", nil, labels),
+	}
+}
+
+// withTimeout sets a deadline for each call to the collector. It has no effect if the
+// collector doesn't implement contextCollector.
+func (c *instrumentedCollector) withTimeout(ctx context.Context, timeout time.Duration, timeouts *prometheus.CounterVec) *instrumentedCollector {
+	c.ctx = ctx
+	c.timeout = timeout
+	c.timeouts = timeouts
+
+	return c
+}
+
+// withDescriptions makes the collector checked by the registry. See Describe.
+func (c *instrumentedCollector) withDescriptions() *instrumentedCollector {
+	c.checked = true
+
+	return c
+}
+
+// Describe sends no descriptions, so the collector is unchecked. The collectors describe their
+// metrics by collecting them and, since the registries are made on each scrape, they would run
+// twice per scrape, once when registered. If the collector is checked, it runs here instead, and
+// the next Collect sends the same metrics.
+func (c *instrumentedCollector) Describe(ch chan<- *prometheus.Desc) {
+	if !c.checked {
+		return
+	}
+
+	metrics := make(chan prometheus.Metric)
+
+	go func() {
+		c.collectInstrumented(metrics)
+		close(metrics)
+	}()
+
+	var pending []prometheus.Metric
+
+	for m := range metrics {
+		pending = append(pending, m)
+
+		// The registration would fail with the description of an invalid metric. The gathering
+		// reports the metric error instead.
+		if err := m.Write(&dto.Metric{}); err == nil {
+			ch <- m.Desc()
+		}
+	}
+
+	c.m.Lock()
+	c.pending = pending
+	c.m.Unlock()
+}
+
+func (c *instrumentedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.m.Unlock()
+
+	if pending != nil {
+		for _, m := range pending {
+			ch <- m
+		}
+
+		return
+	}
+
+	c.collectInstrumented(ch)
+}
+
+// collectInstrumented runs the collector and sends its metrics, its duration and whether it succeeded.
+func (c *instrumentedCollector) collectInstrumented(ch chan<- prometheus.Metric) {
+	start := time.Now()
+
+	var success bool
+
+	timedOut := c.withDeadline(func() {
+		success = c.collect(ch)
+	})
+
+	duration := time.Since(start)
+
+	if timedOut {
+		c.logger.Warnf("collector %s timed out after %s", c.name, c.timeout)
+		c.timeouts.WithLabelValues(c.name).Inc()
+
+		success = false
+	}
+
+	value := float64(0)
+	if success {
+		value = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, duration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.successDesc, prometheus.GaugeValue, value)
+}
+
+// withDeadline runs f with the collector context limited by the timeout, if set.
+// It returns whether the deadline was exceeded.
+func (c *instrumentedCollector) withDeadline(f func()) bool {
+	cc, ok := c.collector.(contextCollector)
+	if c.timeout <= 0 || !ok {
+		f()
+
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	defer cancel()
+
+	cc.setContext(ctx)
+	f()
+
+	// If the parent context is done, the scrape was cancelled, not only this collector.
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && c.ctx.Err() == nil
+}
+
+func (c *instrumentedCollector) collect(ch chan<- prometheus.Metric) (success bool) {
+	metrics := make(chan prometheus.Metric)
+	valid := make(chan bool)
+
+	go func() {
+		ok := true
+
+		for m := range metrics {
+			if err := m.Write(&dto.Metric{}); err != nil {
+				ok = false
+			}
+			ch <- m
+		}
+
+		valid <- ok
+	}()
+
+	defer func() {
+		panicked := false
+		if r := recover(); r != nil {
+			c.logger.Errorf("collector %s panicked: %v", c.name, r)
+			panicked = true
+		}
+
+		close(metrics)
+		success = <-valid && !panicked && !c.collectorFailed()
+	}()
+
+	c.collector.Collect(metrics)
+
+	return true
+}
+
+// collectorFailed returns true if the collector reports a failure.
+func (c *instrumentedCollector) collectorFailed() bool {
+	fr, ok := c.collector.(failureReporter)
+
+	return ok && fr.collectFailed()
+}
+
+var _ prometheus.Collector = (*instrumentedCollector)(nil)
@@ -0,0 +1,236 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type fakeCollector struct {
+	collect func(ch chan<- prometheus.Metric)
+}
+
+func (f *fakeCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(f, ch)
+}
+
+func (f *fakeCollector) Collect(ch chan<- prometheus.Metric) {
+	f.collect(ch)
+}
+
+func TestInstrumentedCollector(t *testing.T) {
+	desc := prometheus.NewDesc("mongodb_fake", "Fake metric", nil, nil)
+	errFake := fmt.Errorf("fake error")
+
+	testCases := []struct {
+		name        string
+		collect     func(ch chan<- prometheus.Metric)
+		wantSuccess float64
+		wantMetrics int
+	}{
+		{
+			name: "success",
+			collect: func(ch chan<- prometheus.Metric) {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1)
+			},
+			wantSuccess: 1,
+			wantMetrics: 3,
+		},
+		{
+			name: "invalid metric",
+			collect: func(ch chan<- prometheus.Metric) {
+				ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(errFake), errFake)
+			},
+			wantSuccess: 0,
+			wantMetrics: 3,
+		},
+		{
+			name: "panic",
+			collect: func(ch chan<- prometheus.Metric) {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1)
+				panic("fake panic")
+			},
+			wantSuccess: 0,
+			wantMetrics: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := newInstrumentedCollector("fake", &fakeCollector{collect: tc.collect}, logrus.New())
+
+			metrics := helpers.CollectMetrics(c)
+			require.Len(t, metrics, tc.wantMetrics)
+
+			var gotDuration, gotSuccess bool
+			for _, m := range metrics {
+				// Invalid metrics cannot be read.
+				if err := m.Write(&dto.Metric{}); err != nil {
+					continue
+				}
+
+				rm := helpers.ReadMetric(m)
+				switch rm.Name {
+				case "mongodb_collector_scrape_duration_seconds":
+					gotDuration = true
+					assert.Equal(t, prometheus.Labels{"collector": "fake"}, rm.Labels)
+				case "mongodb_collector_success":
+					gotSuccess = true
+					assert.Equal(t, tc.wantSuccess, rm.Value)
+				}
+			}
+
+			assert.True(t, gotDuration)
+			assert.True(t, gotSuccess)
+		})
+	}
+}
+
+func TestInstrumentedCollectorCommandFailure(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing listens on this port, so serverStatus fails.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	defer client.Disconnect(ctx) //nolint:errcheck
+
+	cc := &connectionsCollector{
+		ctx:          ctx,
+		serverStatus: newScrapeServerStatus(client),
+		logger:       logrus.New(),
+		topologyInfo: labelsGetterMock{},
+	}
+
+	c := newInstrumentedCollector("connections", cc, logrus.New())
+
+	metrics := helpers.CollectMetrics(c)
+	// Only the duration and success metrics.
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "mongodb_collector_success", helpers.ReadMetric(metrics[1]).Name)
+	assert.Equal(t, float64(0), helpers.ReadMetric(metrics[1]).Value)
+}
+
+// slowCollector takes a second to collect unless its context is done before.
+type slowCollector struct {
+	ctx context.Context
+}
+
+func (f *slowCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("mongodb_fake", "Fake metric", nil, nil)
+}
+
+func (f *slowCollector) setContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+func (f *slowCollector) Collect(ch chan<- prometheus.Metric) {
+	select {
+	case <-f.ctx.Done():
+	case <-time.After(time.Second):
+		ch <- prometheus.MustNewConstMetric(prometheus.NewDesc("mongodb_fake", "Fake metric", nil, nil), prometheus.GaugeValue, 1)
+	}
+}
+
+func TestInstrumentedCollectorTimeout(t *testing.T) {
+	timeouts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "mongodb_collector_timeout_total"}, []string{"collector"})
+
+	c := newInstrumentedCollector("slow", &slowCollector{ctx: context.Background()}, logrus.New())
+	c.withTimeout(context.Background(), 50*time.Millisecond, timeouts)
+
+	start := time.Now()
+	metrics := helpers.CollectMetrics(c)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// Only the duration and success metrics.
+	require.Len(t, metrics, 2)
+	assert.Equal(t, float64(0), helpers.ReadMetric(metrics[1]).Value)
+	assert.Equal(t, float64(1), testutil.ToFloat64(timeouts.WithLabelValues("slow")))
+
+	// A cancelled scrape is not a collector timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.withTimeout(ctx, 50*time.Millisecond, timeouts)
+
+	helpers.CollectMetrics(c)
+	assert.Equal(t, float64(1), testutil.ToFloat64(timeouts.WithLabelValues("slow")))
+}
+
+func TestInstrumentedCollectorRegistry(t *testing.T) {
+	desc := prometheus.NewDesc("mongodb_fake", "Fake metric", nil, nil)
+
+	for _, pedantic := range []bool{false, true} {
+		var calls int
+
+		fc := &fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+			calls++
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(calls))
+		}}
+
+		c := newInstrumentedCollector("fake", fc, logrus.New())
+		registry := prometheus.NewRegistry()
+
+		if pedantic {
+			c.withDescriptions()
+			registry = prometheus.NewPedanticRegistry()
+		}
+
+		registry.MustRegister(c)
+
+		mfs, err := registry.Gather()
+		require.NoError(t, err)
+
+		// The collector runs once per scrape, even if the registry checks its metrics.
+		assert.Equal(t, 1, calls, "pedantic: %t", pedantic)
+		assert.Len(t, mfs, 3)
+
+		_, err = registry.Gather()
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls, "pedantic: %t", pedantic)
+	}
+}
+
+func TestInstrumentedCollectorTimeoutRegistry(t *testing.T) {
+	timeouts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "mongodb_collector_timeout_total"}, []string{"collector"})
+
+	c := newInstrumentedCollector("slow", &slowCollector{ctx: context.Background()}, logrus.New())
+	c.withTimeout(context.Background(), 50*time.Millisecond, timeouts)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	_, err := registry.Gather()
+	require.NoError(t, err)
+
+	// The registration doesn't run the collector, so there is a single timeout per scrape.
+	assert.Equal(t, float64(1), testutil.ToFloat64(timeouts.WithLabelValues("slow")))
+}
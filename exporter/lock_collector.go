@@ -0,0 +1,153 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// lockCollector exposes the global lock queues and active clients from serverStatus().globalLock
+// and the lock acquisitions per resource from serverStatus().locks.
+type lockCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *lockCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *lockCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *lockCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	labels := d.topologyInfo.baseLabels()
+
+	for _, metric := range globalLockMetrics(m, labels) {
+		ch <- metric
+	}
+
+	if locks, ok := m["locks"].(bson.M); ok {
+		for _, metric := range lockAcquisitionMetrics(locks, labels) {
+			ch <- metric
+		}
+	}
+}
+
+func globalLockMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	var defs []fieldMetric
+
+	for _, t := range []string{"total", "readers", "writers"} {
+		defs = append(defs,
+			fieldMetric{
+				path:   []string{"globalLock", "currentQueue", t},
+				name:   "mongodb_global_lock_current_queue",
+				help:   "Number of operations queued waiting for the global lock",
+				vt:     prometheus.GaugeValue,
+				labels: map[string]string{"type": t},
+			},
+			fieldMetric{
+				path:   []string{"globalLock", "activeClients", t},
+				name:   "mongodb_global_lock_active_clients",
+				help:   "Number of connected clients performing read or write operations",
+				vt:     prometheus.GaugeValue,
+				labels: map[string]string{"type": t},
+			},
+		)
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+// lockAcquisitionMetrics builds the metrics from serverStatus().locks. Since MongoDB 3.0 each
+// resource (Global, Database, Collection, etc.) has documents per lock mode (r, w, R, W) with the
+// counters. Older versions have a different layout, per database and without acquireCount, so
+// only the fields present are used.
+func lockAcquisitionMetrics(locks bson.M, labels map[string]string) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	for resource, stats := range locks {
+		s, ok := stats.(bson.M)
+		if !ok {
+			continue
+		}
+
+		for _, def := range []struct {
+			field, name, help string
+		}{
+			{
+				field: "acquireCount",
+				name:  "mongodb_locks_acquire_total",
+				help:  "Number of times the lock was acquired in the mode",
+			},
+			{
+				field: "acquireWaitCount",
+				name:  "mongodb_locks_acquire_wait_total",
+				help:  "Number of times the lock acquisition had to wait because of conflicting locks",
+			},
+			{
+				field: "timeAcquiringMicros",
+				name:  "mongodb_locks_time_acquiring_microseconds_total",
+				help:  "Time spent waiting for the lock acquisitions, in microseconds",
+			},
+			{
+				field: "deadlockCount",
+				name:  "mongodb_locks_deadlock_total",
+				help:  "Number of times the lock acquisition found a deadlock",
+			},
+		} {
+			modes, ok := s[def.field].(bson.M)
+			if !ok {
+				continue
+			}
+
+			defs := make([]fieldMetric, 0, len(modes))
+			for mode := range modes {
+				defs = append(defs, fieldMetric{
+					path:   []string{mode},
+					name:   def.name,
+					help:   def.help,
+					vt:     prometheus.CounterValue,
+					labels: map[string]string{"resource": resource, "mode": mode},
+				})
+			}
+
+			metrics = append(metrics, fieldMetrics(modes, defs, labels)...)
+		}
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*lockCollector)(nil)
@@ -0,0 +1,95 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGlobalLockMetrics(t *testing.T) {
+	m := bson.M{
+		"globalLock": bson.M{
+			"totalTime":     int64(1000),
+			"currentQueue":  bson.M{"total": int32(3), "readers": int32(1), "writers": int32(2)},
+			"activeClients": bson.M{"total": int32(5), "readers": int32(4), "writers": int32(1)},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_global_lock_active_clients Number of connected clients performing read or write operations",
+		"# TYPE mongodb_global_lock_active_clients gauge",
+		`mongodb_global_lock_active_clients{rs_nm="rs1",type="readers"} 4`,
+		`mongodb_global_lock_active_clients{rs_nm="rs1",type="total"} 5`,
+		`mongodb_global_lock_active_clients{rs_nm="rs1",type="writers"} 1`,
+		"# HELP mongodb_global_lock_current_queue Number of operations queued waiting for the global lock",
+		"# TYPE mongodb_global_lock_current_queue gauge",
+		`mongodb_global_lock_current_queue{rs_nm="rs1",type="readers"} 1`,
+		`mongodb_global_lock_current_queue{rs_nm="rs1",type="total"} 3`,
+		`mongodb_global_lock_current_queue{rs_nm="rs1",type="writers"} 2`,
+	}
+
+	metrics := globalLockMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
+
+func TestLockAcquisitionMetrics(t *testing.T) {
+	locks := bson.M{
+		"Global": bson.M{
+			"acquireCount":        bson.M{"r": int64(10), "w": int64(2)},
+			"acquireWaitCount":    bson.M{"r": int64(1)},
+			"timeAcquiringMicros": bson.M{"r": int64(250)},
+		},
+		"Database": bson.M{
+			"acquireCount": bson.M{"W": int64(3)},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_locks_acquire_total Number of times the lock was acquired in the mode",
+		"# TYPE mongodb_locks_acquire_total counter",
+		`mongodb_locks_acquire_total{mode="W",resource="Database"} 3`,
+		`mongodb_locks_acquire_total{mode="r",resource="Global"} 10`,
+		`mongodb_locks_acquire_total{mode="w",resource="Global"} 2`,
+		"# HELP mongodb_locks_acquire_wait_total Number of times the lock acquisition had to wait because of conflicting locks",
+		"# TYPE mongodb_locks_acquire_wait_total counter",
+		`mongodb_locks_acquire_wait_total{mode="r",resource="Global"} 1`,
+		"# HELP mongodb_locks_time_acquiring_microseconds_total Time spent waiting for the lock acquisitions, in microseconds",
+		"# TYPE mongodb_locks_time_acquiring_microseconds_total counter",
+		`mongodb_locks_time_acquiring_microseconds_total{mode="r",resource="Global"} 250`,
+	}
+	assert.Equal(t, want, helpers.Format(lockAcquisitionMetrics(locks, nil)))
+
+	// MongoDB 2.6 has the locks per database, with the time locked instead of the counters.
+	old := bson.M{
+		".": bson.M{
+			"timeLockedMicros":    bson.M{"R": int64(100), "W": int64(50)},
+			"timeAcquiringMicros": bson.M{"R": int64(20), "W": int64(5)},
+		},
+	}
+
+	want = []string{
+		"# HELP mongodb_locks_time_acquiring_microseconds_total Time spent waiting for the lock acquisitions, in microseconds",
+		"# TYPE mongodb_locks_time_acquiring_microseconds_total counter",
+		`mongodb_locks_time_acquiring_microseconds_total{mode="R",resource="."} 20`,
+		`mongodb_locks_time_acquiring_microseconds_total{mode="W",resource="."} 5`,
+	}
+	assert.Equal(t, want, helpers.Format(lockAcquisitionMetrics(old, nil)))
+}
@@ -0,0 +1,104 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// serverStatus().mem values are in mebibytes.
+const mebibyte = 1024 * 1024
+
+// memoryCollector exposes the process memory usage from serverStatus().mem and the allocator
+// usage from serverStatus().tcmalloc.
+type memoryCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *memoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *memoryCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *memoryCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range memoryMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// memoryMetrics builds the memory metrics in bytes. The mapped fields exist only with MMAPv1 and
+// tcmalloc only if the server uses it, so only the fields present are returned.
+func memoryMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path:   []string{"tcmalloc", "generic", "current_allocated_bytes"},
+			name:   "mongodb_memory_tcmalloc_bytes",
+			help:   "Memory managed by tcmalloc, in bytes",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "allocated"},
+		},
+		{
+			path:   []string{"tcmalloc", "generic", "heap_size"},
+			name:   "mongodb_memory_tcmalloc_bytes",
+			help:   "Memory managed by tcmalloc, in bytes",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "heap"},
+		},
+		{
+			path:   []string{"tcmalloc", "tcmalloc", "pageheap_free_bytes"},
+			name:   "mongodb_memory_tcmalloc_bytes",
+			help:   "Memory managed by tcmalloc, in bytes",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "pageheap_free"},
+		},
+	}
+
+	for _, t := range []string{"resident", "virtual", "mapped", "mappedWithJournal"} {
+		defs = append(defs, fieldMetric{
+			path:   []string{"mem", t},
+			name:   "mongodb_memory_bytes",
+			help:   "Memory used by the mongod or mongos process, in bytes",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": t},
+			scale:  mebibyte,
+		})
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*memoryCollector)(nil)
@@ -0,0 +1,77 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	// WiredTiger server, without the mapped fields.
+	m := bson.M{
+		"mem": bson.M{
+			"bits":      int32(64),
+			"resident":  int32(100),
+			"virtual":   int32(1500),
+			"supported": true,
+		},
+		"tcmalloc": bson.M{
+			"generic": bson.M{
+				"current_allocated_bytes": int64(90000000),
+				"heap_size":               int64(120000000),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_memory_bytes Memory used by the mongod or mongos process, in bytes",
+		"# TYPE mongodb_memory_bytes gauge",
+		`mongodb_memory_bytes{rs_nm="rs1",type="resident"} 1.048576e+08`,
+		`mongodb_memory_bytes{rs_nm="rs1",type="virtual"} 1.572864e+09`,
+		"# HELP mongodb_memory_tcmalloc_bytes Memory managed by tcmalloc, in bytes",
+		"# TYPE mongodb_memory_tcmalloc_bytes gauge",
+		`mongodb_memory_tcmalloc_bytes{rs_nm="rs1",type="allocated"} 9e+07`,
+		`mongodb_memory_tcmalloc_bytes{rs_nm="rs1",type="heap"} 1.2e+08`,
+	}
+
+	metrics := memoryMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// MMAPv1 server.
+	m = bson.M{
+		"mem": bson.M{
+			"resident":          int32(1),
+			"virtual":           int32(2),
+			"mapped":            int32(3),
+			"mappedWithJournal": int32(6),
+		},
+	}
+
+	want = []string{
+		"# HELP mongodb_memory_bytes Memory used by the mongod or mongos process, in bytes",
+		"# TYPE mongodb_memory_bytes gauge",
+		`mongodb_memory_bytes{type="mapped"} 3.145728e+06`,
+		`mongodb_memory_bytes{type="mappedWithJournal"} 6.291456e+06`,
+		`mongodb_memory_bytes{type="resident"} 1.048576e+06`,
+		`mongodb_memory_bytes{type="virtual"} 2.097152e+06`,
+	}
+	assert.Equal(t, want, helpers.Format(memoryMetrics(m, nil)))
+}
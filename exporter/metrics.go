@@ -23,6 +23,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -233,7 +234,38 @@ func metricHelp(prefix, name string) string {
 	return name
 }
 
-func makeMetrics(prefix string, m bson.M, labels map[string]string, compatibleMode bool) []prometheus.Metric {
+func newCompatibleModeRemapsCounter() prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mongodb_exporter_compatible_mode_remaps_total",
+		Help: "Number of metrics converted to the old format by the compatible mode",
+	})
+}
+
+// compatibleRemaps counts the metrics in the old format made by the compatible mode and logs them.
+// The counter is kept by the exporter across the scrapes. If it's nil, the metrics are only logged.
+type compatibleRemaps struct {
+	counter prometheus.Counter
+	logger  *logrus.Logger
+}
+
+// add counts and logs the metrics. It does nothing on a nil compatibleRemaps.
+func (r *compatibleRemaps) add(metrics []prometheus.Metric) {
+	if r == nil {
+		return
+	}
+
+	if r.counter != nil {
+		r.counter.Add(float64(len(metrics)))
+	}
+
+	for _, metric := range metrics {
+		r.logger.Debugf("compatible mode metric: %s", metric.Desc())
+	}
+}
+
+// makeMetrics builds the metrics from the document and, in compatible mode, their copies in the old
+// format, added to remaps.
+func makeMetrics(prefix string, m bson.M, labels map[string]string, compatibleMode bool, remaps *compatibleRemaps) []prometheus.Metric {
 	var res []prometheus.Metric
 
 	if prefix != "" {
@@ -243,12 +275,12 @@ func makeMetrics(prefix string, m bson.M, labels map[string]string, compatibleMo
 	for k, val := range m {
 		switch v := val.(type) {
 		case bson.M:
-			res = append(res, makeMetrics(prefix+k, v, labels, compatibleMode)...)
+			res = append(res, makeMetrics(prefix+k, v, labels, compatibleMode, remaps)...)
 		case map[string]interface{}:
-			res = append(res, makeMetrics(prefix+k, v, labels, compatibleMode)...)
+			res = append(res, makeMetrics(prefix+k, v, labels, compatibleMode, remaps)...)
 		case primitive.A:
 			v = []interface{}(v)
-			res = append(res, processSlice(prefix, k, v, labels, compatibleMode)...)
+			res = append(res, processSlice(prefix, k, v, labels, compatibleMode, remaps)...)
 		case []interface{}:
 			continue
 		default:
@@ -282,7 +314,9 @@ func makeMetrics(prefix string, m bson.M, labels map[string]string, compatibleMo
 				res = append(res, metric)
 
 				if compatibleMode {
+					n := len(res)
 					res = appendCompatibleMetric(res, m)
+					remaps.add(res[n:])
 				}
 			}
 		}
@@ -293,7 +327,8 @@ func makeMetrics(prefix string, m bson.M, labels map[string]string, compatibleMo
 
 // Extract maps from arrays. Only some structures like replicasets have arrays of members
 // and each member is represented by a map[string]interface{}.
-func processSlice(prefix, k string, v []interface{}, commonLabels map[string]string, compatibleMode bool) []prometheus.Metric {
+func processSlice(prefix, k string, v []interface{}, commonLabels map[string]string, compatibleMode bool,
+	remaps *compatibleRemaps) []prometheus.Metric {
 	metrics := make([]prometheus.Metric, 0)
 	labels := make(map[string]string)
 	for name, value := range commonLabels {
@@ -320,7 +355,7 @@ func processSlice(prefix, k string, v []interface{}, commonLabels map[string]str
 			labels["member_state"] = state
 		}
 
-		metrics = append(metrics, makeMetrics(prefix+k, s, labels, compatibleMode)...)
+		metrics = append(metrics, makeMetrics(prefix+k, s, labels, compatibleMode, remaps)...)
 	}
 
 	return metrics
@@ -423,3 +458,88 @@ func specialConversions() []conversion {
 		},
 	}
 }
+
+// fieldMetric describes a metric built from a single numeric field in a document.
+type fieldMetric struct {
+	// Path of the field in the document
+	path []string
+	// Full Qualified Name
+	name string
+	// Help string
+	help string
+	// Value type
+	vt prometheus.ValueType
+	// Extra labels, added to the common labels
+	labels map[string]string
+	// Multiplier to convert the value to the metric unit. If zero, the value is used as is
+	scale float64
+}
+
+// fieldMetrics builds the metrics defined in defs. Fields not present in the document are skipped
+// so, collectors can handle sections that don't exist in all MongoDB versions.
+func fieldMetrics(m bson.M, defs []fieldMetric, labels map[string]string) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(defs))
+
+	for _, def := range defs {
+		val := walkTo(m, def.path)
+		if val == nil {
+			continue
+		}
+
+		f, err := asFloat64(val)
+		if err != nil {
+			metrics = append(metrics, prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err))
+			continue
+		}
+
+		if f == nil {
+			continue
+		}
+
+		constLabels := prometheus.Labels{}
+		for k, v := range labels {
+			constLabels[k] = v
+		}
+
+		for k, v := range def.labels {
+			constLabels[k] = v
+		}
+
+		if def.scale != 0 {
+			*f *= def.scale
+		}
+
+		d := prometheus.NewDesc(def.name, def.help, nil, constLabels)
+
+		metric, err := prometheus.NewConstMetric(d, def.vt, *f)
+		if err != nil {
+			metrics = append(metrics, prometheus.NewInvalidMetric(d, err))
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return metrics
+}
+
+// setNamespaceLabels sets the namespace label and, if split is true, the database and collection
+// labels too.
+func setNamespaceLabels(labels map[string]string, ns string, split bool) {
+	labels["namespace"] = ns
+
+	if split {
+		labels["database"], labels["collection"] = splitNamespace(ns)
+	}
+}
+
+// splitNamespace splits a namespace in its database and collection names.
+// Collection names can have dots so, only the first one is used as separator.
+func splitNamespace(ns string) (string, string) {
+	parts := strings.SplitN(ns, ".", 2) //nolint:gomnd
+	if len(parts) != 2 {                //nolint:gomnd
+		return ns, ""
+	}
+
+	return parts[0], parts[1]
+}
@@ -17,13 +17,22 @@
 package exporter
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/AlekSi/pointer"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Test metric renaming and labeling.
@@ -216,3 +225,92 @@ func TestRawToCompatibleRawMetric(t *testing.T) {
 		assert.Equal(t, m[0], tc.want)
 	}
 }
+
+func TestCompatibleModeRemaps(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Nothing listens on this port, so the metrics needing the server, like myState, are skipped.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	m := bson.M{"serverStatus": bson.M{
+		"asserts":       bson.M{"regular": int32(1), "warning": int32(0)},
+		"storageEngine": bson.M{"name": "wiredTiger"},
+		"version":       "4.4.0",
+	}}
+
+	remaps := &compatibleRemaps{counter: newCompatibleModeRemapsCounter(), logger: logger}
+
+	makeMetrics("", m, nil, false, remaps)
+	assert.Equal(t, float64(0), testutil.ToFloat64(remaps.counter))
+
+	res := makeMetrics("", m, nil, true, remaps)
+	// Each assert metric has a copy in the old format.
+	assert.Len(t, res, 4)
+	assert.Equal(t, float64(2), testutil.ToFloat64(remaps.counter))
+
+	// Some metrics, like the storage engine and the version, only exist in the old format.
+	d := &diagnosticDataCollector{ctx: ctx, client: client, compatibleMode: true, remaps: remaps, logger: logger}
+	assert.Len(t, d.compatibleMetrics(m), 4)
+	assert.Equal(t, float64(6), testutil.ToFloat64(remaps.counter))
+
+	// Without a counter, for the /scrape targets, they are only logged.
+	makeMetrics("", m, nil, true, &compatibleRemaps{logger: logger})
+
+	var logged []string
+
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.DebugLevel && strings.HasPrefix(entry.Message, "compatible mode metric: ") {
+			logged = append(logged, entry.Message)
+		}
+	}
+
+	require.Len(t, logged, 8)
+	assert.Contains(t, logged[0], `fqName: "mongodb_asserts_total"`)
+	assert.Contains(t, logged[1], `fqName: "mongodb_asserts_total"`)
+	assert.Contains(t, logged[2], `fqName: "mongodb_mongod_locks_time_acquiring_global_microseconds_total"`)
+	assert.Contains(t, logged[3], `fqName: "mongodb_mongod_storage_engine"`)
+	assert.Contains(t, logged[4], `fqName: "mongodb_version_info"`)
+	assert.Contains(t, logged[5], `fqName: "mongodb_mongod_wiredtiger_cache_evicted_total"`)
+
+	assert.NoError(t, client.Disconnect(ctx))
+}
+
+func TestSplitNamespace(t *testing.T) {
+	testCases := []struct {
+		ns         string
+		db         string
+		collection string
+	}{
+		{ns: "db.col", db: "db", collection: "col"},
+		{ns: "db.system.profile", db: "db", collection: "system.profile"},
+		{ns: "db", db: "db", collection: ""},
+	}
+
+	for _, tc := range testCases {
+		db, collection := splitNamespace(tc.ns)
+		assert.Equal(t, tc.db, db)
+		assert.Equal(t, tc.collection, collection)
+	}
+}
+
+func TestSetNamespaceLabels(t *testing.T) {
+	labels := map[string]string{"rs_nm": "rs1"}
+	setNamespaceLabels(labels, "db.system.profile", false)
+	assert.Equal(t, map[string]string{"rs_nm": "rs1", "namespace": "db.system.profile"}, labels)
+
+	labels = map[string]string{"rs_nm": "rs1"}
+	setNamespaceLabels(labels, "db.system.profile", true)
+	want := map[string]string{
+		"rs_nm":      "rs1",
+		"namespace":  "db.system.profile",
+		"database":   "db",
+		"collection": "system.profile",
+	}
+	assert.Equal(t, want, labels)
+}
@@ -0,0 +1,477 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// scrapeHandler serves the metrics of the MongoDB instance in the target query parameter.
+// Unlike the metrics handler, it uses a new connection, unless ConnectionReuse is enabled, and
+// doesn't include the exporter own metrics.
+func (e *Exporter) scrapeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.isClosed() {
+			http.Error(w, errExporterClosed.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		ctx := r.Context()
+
+		target := r.URL.Query().Get("target")
+		opts := e.targetOpts(target)
+
+		dsn, err := targetDSN(target, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		client, topologyInfo, release, err := e.targetClient(ctx, dsn, opts)
+		if err != nil {
+			e.logger.Errorf("Cannot connect to MongoDB target: %v", err)
+			http.Error(
+				w,
+				"An error has occurred while connecting to MongoDB:\n\n"+err.Error(),
+				http.StatusInternalServerError,
+			)
+
+			return
+		}
+
+		defer release()
+
+		te := e.targetExporter(target, dsn, opts)
+
+		h := promhttp.HandlerFor(te.gatherer(te.makeRegistry(ctx, client, topologyInfo)), promhttp.HandlerOpts{
+			ErrorHandling: promhttp.ContinueOnError,
+			ErrorLog:      e.logger,
+		})
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// targetExporter returns the exporter to make the registry of a target, with the target options
+// and its DSN as the URI.
+func (e *Exporter) targetExporter(target, dsn string, opts *Opts) *Exporter {
+	targetOpts := *opts
+	targetOpts.URI = dsn
+
+	// The collStats cache is indexed by namespace, so it cannot be shared by different targets.
+	te := &Exporter{
+		logger:            e.logger,
+		opts:              &targetOpts,
+		collectorTimeouts: e.collectorTimeouts,
+		collections:       e.collectionLists(),
+		collStatsTypes:    e.collStatsTypes,
+		customQueries:     e.customQueries,
+		metricAllow:       e.metricAllow,
+		metricDeny:        e.metricDeny,
+	}
+
+	// The config servers are the ones the target reports, so they only get the credentials of the
+	// URI if the target can get them. Otherwise, the config servers collector is not used.
+	if e.targetGetsCredentials(target) {
+		te.configServerClients = e.configServerClients
+	}
+
+	return te
+}
+
+// targetOpts returns the options to connect to the target, without the credentials of the URI and
+// the authentication options if the target cannot get them or has its own credentials. The client
+// certificate is only sent to the hosts of the URI, even with MultiTargetCredentials.
+func (e *Exporter) targetOpts(target string) *Opts {
+	opts := *e.opts
+
+	if !uriHasTargetHosts(e.opts.URI, target) {
+		opts.URI = uriWithoutClientCertificate(opts.URI)
+		opts.TLSCertificateKeyFile = ""
+	}
+
+	if !e.targetGetsCredentials(target) || targetHasCredentials(target) {
+		opts.URI = ""
+		opts.AuthMechanism = ""
+		opts.AuthSource = ""
+		opts.UsernameFile = ""
+		opts.PasswordFile = ""
+		opts.AWSSessionTokenFile = ""
+	}
+
+	return &opts
+}
+
+// targetHasCredentials returns true if the target URI has its own user info.
+func targetHasCredentials(target string) bool {
+	_, userInfo, _, _, _ := splitURI(normalizeURI(target))
+
+	return userInfo != ""
+}
+
+// clientCertificateOptions are the URI options sending a client certificate.
+var clientCertificateOptions = []string{
+	"tlsCertificateKeyFile",
+	"tlsCertificateKeyFilePassword",
+	"tlsCertificateFile",
+	"tlsPrivateKeyFile",
+	"sslClientCertificateKeyFile",
+	"sslClientCertificateKeyPassword",
+}
+
+// uriWithoutClientCertificate returns the URI without the client certificate options. An URI with
+// invalid options is returned as is, it fails later anyway.
+func uriWithoutClientCertificate(uri string) string {
+	i := strings.Index(uri, "?")
+	if i < 0 {
+		return uri
+	}
+
+	q, err := url.ParseQuery(uri[i+1:])
+	if err != nil {
+		return uri
+	}
+
+	// The option names are case insensitive.
+	for key := range q {
+		for _, option := range clientCertificateOptions {
+			if strings.EqualFold(key, option) {
+				delete(q, key)
+			}
+		}
+	}
+
+	if len(q) == 0 {
+		return uri[:i]
+	}
+
+	return uri[:i+1] + q.Encode()
+}
+
+// targetGetsCredentials returns true if the credentials of the URI and the authentication options can
+// be sent to the target. Anyone reaching /scrape can choose the target so, they are only sent to the
+// hosts of the URI, unless MultiTargetCredentials is set.
+func (e *Exporter) targetGetsCredentials(target string) bool {
+	return e.opts.MultiTargetCredentials || uriHasTargetHosts(e.opts.URI, target)
+}
+
+// uriHasTargetHosts returns true if all the hosts of the target are in the URI. The hosts without
+// port use the default one.
+func uriHasTargetHosts(uri, target string) bool {
+	u, err := connstring.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	t, err := connstring.Parse(normalizeURI(target))
+	if err != nil || len(t.Hosts) == 0 {
+		return false
+	}
+
+	hosts := make(map[string]bool, len(u.Hosts))
+	for _, host := range u.Hosts {
+		hosts[hostWithPort(host)] = true
+	}
+
+	for _, host := range t.Hosts {
+		if !hosts[hostWithPort(host)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hostWithPort(host string) string {
+	host = strings.ToLower(host)
+
+	// Unix socket paths have no port.
+	if strings.HasSuffix(host, ".sock") {
+		return host
+	}
+
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), "27017")
+}
+
+// targetClient returns a client for the target and its topology info, from the client cache if
+// enabled, and a function to call when the scrape is done with the client.
+func (e *Exporter) targetClient(ctx context.Context, dsn string, opts *Opts) (*mongo.Client, labelsGetter, func(), error) {
+	if e.clientCache != nil {
+		return e.clientCache.get(ctx, dsn, opts)
+	}
+
+	client, err := connect(ctx, dsn, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	release := func() {
+		if err := client.Disconnect(ctx); err != nil {
+			e.logger.Errorf("Cannot disconnect mongo client: %v", err)
+		}
+	}
+
+	topologyInfo, err := newTopologyInfo(ctx, client)
+	if err != nil {
+		release()
+
+		return nil, nil, nil, errors.Wrap(err, "cannot get topology info")
+	}
+
+	return client, topologyInfo, release, nil
+}
+
+// targetDSN builds the connection string for the target. The target can be a MongoDB URI, just
+// host:port or a Unix socket path. If the target has no credentials, the ones from the URI and the
+// authentication options, if any, are used. Then, the database and the options, like authSource,
+// authMechanism or the TLS ones, are the URI ones too, and the target can only choose the
+// topology options, so it cannot change how the credentials are sent.
+func targetDSN(target string, opts *Opts) (string, error) {
+	if target == "" {
+		return "", errors.New("the target parameter is missing")
+	}
+
+	target = normalizeURI(target)
+
+	// Don't show the target in the errors since it might have credentials.
+	t, err := connstring.Parse(target)
+	if err != nil {
+		return "", errors.New("invalid target: it must be a MongoDB URI, host:port or a socket path")
+	}
+
+	if t.Username == "" && hasCredentials(opts) {
+		scheme, _, hosts, _, query := splitURI(target)
+
+		options, err := url.ParseQuery(query)
+		if err != nil {
+			return "", errors.New("invalid target: it must be a MongoDB URI, host:port or a socket path")
+		}
+
+		for key := range options {
+			if !isTopologyOption(key) {
+				return "", errors.Errorf("invalid target: the %s option cannot be used with the exporter credentials", key)
+			}
+		}
+
+		if target, err = uriWithHosts(opts.URI, scheme, hosts, options); err != nil {
+			return "", errors.Wrap(err, "invalid URI")
+		}
+	}
+
+	if _, err := connstring.ParseAndValidate(target); err != nil {
+		return "", errors.New("invalid target: it must be a MongoDB URI, host:port or a socket path")
+	}
+
+	return target, nil
+}
+
+// hasCredentials returns true if the URI or the authentication options have credentials.
+func hasCredentials(opts *Opts) bool {
+	if opts.AuthMechanism != "" || opts.AuthSource != "" || opts.UsernameFile != "" || opts.PasswordFile != "" ||
+		opts.AWSSessionTokenFile != "" {
+		return true
+	}
+
+	// connstring.Parse is not used since it looks up the mongodb+srv:// hosts.
+	_, userInfo, _, _, query := splitURI(opts.URI)
+	if userInfo != "" {
+		return true
+	}
+
+	for _, option := range strings.Split(query, "&") {
+		if strings.HasPrefix(strings.ToLower(option), "authmechanism=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topologyOptions are the URI options describing the hosts, not how to connect to them.
+var topologyOptions = []string{"replicaSet", "directConnection"}
+
+func isTopologyOption(key string) bool {
+	for _, option := range topologyOptions {
+		if strings.EqualFold(key, option) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uriWithHosts returns the URI with the scheme and the hosts replaced, keeping its user info,
+// database and options but the topology ones, replaced by the given options. Since a
+// mongodb+srv:// URI enables TLS by default, TLS is enabled explicitly when it's replaced by a
+// mongodb:// one.
+func uriWithHosts(uri, scheme, hosts string, options url.Values) (string, error) {
+	uriScheme, userInfo, _, database, query := splitURI(uri)
+
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	// The option names are case insensitive.
+	for key := range q {
+		if isTopologyOption(key) {
+			delete(q, key)
+		}
+	}
+
+	for key, values := range options {
+		q[key] = values
+	}
+
+	if uriScheme == "mongodb+srv" && scheme != "mongodb+srv" {
+		tls := false
+		for key := range q {
+			tls = tls || strings.EqualFold(key, "tls") || strings.EqualFold(key, "ssl")
+		}
+
+		if !tls {
+			q.Set("tls", "true")
+		}
+	}
+
+	res := scheme + "://"
+	if userInfo != "" {
+		res += userInfo + "@"
+	}
+
+	res += hosts
+
+	if database != "" || len(q) > 0 {
+		res += "/" + database
+	}
+
+	if len(q) > 0 {
+		res += "?" + q.Encode()
+	}
+
+	return res, nil
+}
+
+// splitURI returns the scheme, the user info, the hosts, the database and the options of the URI,
+// still escaped. An empty URI has the mongodb scheme.
+func splitURI(uri string) (string, string, string, string, string) {
+	scheme, rest := "mongodb", ""
+	if parts := strings.SplitN(uri, "://", 2); len(parts) == 2 { //nolint:gomnd
+		scheme, rest = parts[0], parts[1]
+	}
+
+	authority, path := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+
+	var userInfo string
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userInfo, authority = authority[:i], authority[i+1:]
+	}
+
+	path = strings.TrimPrefix(path, "/")
+
+	var query string
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+
+	return scheme, userInfo, authority, path, query
+}
+
+// normalizeURI adds the mongodb:// scheme if missing. A Unix socket path, like
+// /tmp/mongodb-27017.sock, is escaped as the driver expects.
+func normalizeURI(uri string) string {
+	if strings.HasPrefix(uri, "/") {
+		return "mongodb://" + url.QueryEscape(uri)
+	}
+
+	if !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		return "mongodb://" + uri
+	}
+
+	return uri
+}
+
+// redactURI removes the user info, with the password, from the URI to log it or to show it in an error.
+func redactURI(uri string) string {
+	userInfo, redacted := splitUserInfo(uri)
+	if userInfo == "" {
+		return uri
+	}
+
+	return redacted
+}
+
+// redactError removes the URI and its user info from the error message, since the driver errors
+// might include them.
+func redactError(err error, uri string) error {
+	if err == nil {
+		return nil
+	}
+
+	userInfo, redacted := splitUserInfo(uri)
+	if userInfo == "" {
+		return err
+	}
+
+	msg := strings.ReplaceAll(err.Error(), uri, redacted)
+	msg = strings.ReplaceAll(msg, userInfo, "[redacted]")
+
+	if msg == err.Error() {
+		return err
+	}
+
+	return errors.New(msg)
+}
+
+// splitUserInfo returns the user info of a MongoDB URI and the URI without it. The user info ends
+// with the last @ before the path or the options, since the hosts list cannot be parsed by url.Parse.
+func splitUserInfo(uri string) (string, string) {
+	parts := strings.SplitN(uri, "://", 2) //nolint:gomnd
+	if len(parts) != 2 {                   //nolint:gomnd
+		return "", uri
+	}
+
+	authority := parts[1]
+	if i := strings.IndexAny(authority, "/?"); i >= 0 {
+		authority = authority[:i]
+	}
+
+	i := strings.LastIndex(authority, "@")
+	if i < 0 {
+		return "", uri
+	}
+
+	return authority[:i], parts[0] + "://" + parts[1][i+1:]
+}
@@ -0,0 +1,89 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// networkCollector exposes the network traffic counters from serverStatus().network.
+type networkCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	// The compatible mode defines mongodb_network_bytes_total, with the state label, from the
+	// diagnostic data so, it must not be exposed twice.
+	compatibleMode bool
+	logger         *logrus.Logger
+	topologyInfo   labelsGetter
+}
+
+func (d *networkCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *networkCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *networkCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range networkMetrics(m, !d.compatibleMode, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// networkMetrics builds the metrics from the counters since the server started. If withBytes is
+// false, the traffic counters are not returned.
+func networkMetrics(m bson.M, withBytes bool, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"network", "numRequests"},
+			name: "mongodb_network_num_requests_total",
+			help: "Number of distinct requests received by the server",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	if withBytes {
+		for direction, field := range map[string]string{"in": "bytesIn", "out": "bytesOut"} {
+			defs = append(defs, fieldMetric{
+				path:   []string{"network", field},
+				name:   "mongodb_network_bytes_total",
+				help:   "Number of bytes received or sent over the network",
+				vt:     prometheus.CounterValue,
+				labels: map[string]string{"direction": direction},
+			})
+		}
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*networkCollector)(nil)
@@ -0,0 +1,52 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNetworkMetrics(t *testing.T) {
+	m := bson.M{
+		"network": bson.M{
+			"bytesIn":     int64(1024),
+			"bytesOut":    int64(4096),
+			"numRequests": int64(12),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_network_bytes_total Number of bytes received or sent over the network",
+		"# TYPE mongodb_network_bytes_total counter",
+		`mongodb_network_bytes_total{direction="in",rs_nm="rs1"} 1024`,
+		`mongodb_network_bytes_total{direction="out",rs_nm="rs1"} 4096`,
+		"# HELP mongodb_network_num_requests_total Number of distinct requests received by the server",
+		"# TYPE mongodb_network_num_requests_total counter",
+		`mongodb_network_num_requests_total{rs_nm="rs1"} 12`,
+	}
+
+	metrics := networkMetrics(m, true, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// In compatible mode, the diagnostic data has the traffic counters.
+	metrics = networkMetrics(m, false, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want[4:], helpers.Format(metrics))
+}
@@ -0,0 +1,91 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// opcountersCollector exposes serverStatus().opcounters and opcountersRepl with stable metric
+// names, independently of the compatible mode.
+type opcountersCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	// The compatible mode already exposes mongodb_op_counters_total from the diagnostic data so,
+	// it must not be exposed twice.
+	compatibleMode bool
+	logger         *logrus.Logger
+	topologyInfo   labelsGetter
+}
+
+func (d *opcountersCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *opcountersCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *opcountersCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range opcountersMetrics(m, !d.compatibleMode, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// opcountersMetrics returns a counter per operation type. If withOpcounters is false, only the
+// replicated operations are returned.
+func opcountersMetrics(m bson.M, withOpcounters bool, labels map[string]string) []prometheus.Metric {
+	var defs []fieldMetric
+
+	for _, op := range []string{"insert", "query", "update", "delete", "getmore", "command"} {
+		if withOpcounters {
+			defs = append(defs, fieldMetric{
+				path:   []string{"opcounters", op},
+				name:   "mongodb_op_counters_total",
+				help:   "Number of database operations by type since the server started",
+				vt:     prometheus.CounterValue,
+				labels: map[string]string{"type": op},
+			})
+		}
+
+		defs = append(defs, fieldMetric{
+			path:   []string{"opcountersRepl", op},
+			name:   "mongodb_op_counters_repl_total",
+			help:   "Number of replicated database operations by type since the server started",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": op},
+		})
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*opcountersCollector)(nil)
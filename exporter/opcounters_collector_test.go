@@ -0,0 +1,63 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestOpcountersMetrics(t *testing.T) {
+	m := bson.M{
+		"opcounters": bson.M{
+			"insert":  int32(4),
+			"query":   int32(2118),
+			"update":  int32(14),
+			"delete":  int32(22),
+			"getmore": int32(9141),
+			"command": int32(67923),
+		},
+		"opcountersRepl": bson.M{
+			"insert": int32(3),
+			"update": int32(1),
+		},
+	}
+
+	labels := map[string]string{labelReplicasetName: "rs1"}
+
+	want := []string{
+		"# HELP mongodb_op_counters_repl_total Number of replicated database operations by type since the server started",
+		"# TYPE mongodb_op_counters_repl_total counter",
+		`mongodb_op_counters_repl_total{rs_nm="rs1",type="insert"} 3`,
+		`mongodb_op_counters_repl_total{rs_nm="rs1",type="update"} 1`,
+		"# HELP mongodb_op_counters_total Number of database operations by type since the server started",
+		"# TYPE mongodb_op_counters_total counter",
+		`mongodb_op_counters_total{rs_nm="rs1",type="command"} 67923`,
+		`mongodb_op_counters_total{rs_nm="rs1",type="delete"} 22`,
+		`mongodb_op_counters_total{rs_nm="rs1",type="getmore"} 9141`,
+		`mongodb_op_counters_total{rs_nm="rs1",type="insert"} 4`,
+		`mongodb_op_counters_total{rs_nm="rs1",type="query"} 2118`,
+		`mongodb_op_counters_total{rs_nm="rs1",type="update"} 14`,
+	}
+	assert.Equal(t, want, helpers.Format(opcountersMetrics(m, true, labels)))
+
+	// In compatible mode, mongodb_op_counters_total comes from the diagnostic data.
+	assert.Equal(t, want[:4], helpers.Format(opcountersMetrics(m, false, labels)))
+}
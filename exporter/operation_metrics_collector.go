@@ -0,0 +1,80 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// operationMetricsCollector exposes the operation counters from serverStatus().metrics.operation.
+// In-memory sorts and write conflicts usually point to missing indexes and to write contention.
+type operationMetricsCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *operationMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *operationMetricsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *operationMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range operationMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// operationMetrics returns no metrics without the metrics.operation section, like in mongos.
+func operationMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"metrics", "operation", "scanAndOrder"},
+			name: "mongodb_metrics_operation_scan_and_order_total",
+			help: "Number of queries that could not use an index to sort the results",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "operation", "writeConflicts"},
+			name: "mongodb_metrics_operation_write_conflicts_total",
+			help: "Number of queries that encountered write conflicts",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*operationMetricsCollector)(nil)
@@ -0,0 +1,51 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestOperationMetrics(t *testing.T) {
+	m := bson.M{
+		"metrics": bson.M{
+			"operation": bson.M{
+				"scanAndOrder":   int64(12),
+				"writeConflicts": int64(3),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_metrics_operation_scan_and_order_total Number of queries that could not use an index to sort the results",
+		"# TYPE mongodb_metrics_operation_scan_and_order_total counter",
+		`mongodb_metrics_operation_scan_and_order_total{rs_nm="rs1"} 12`,
+		"# HELP mongodb_metrics_operation_write_conflicts_total Number of queries that encountered write conflicts",
+		"# TYPE mongodb_metrics_operation_write_conflicts_total counter",
+		`mongodb_metrics_operation_write_conflicts_total{rs_nm="rs1"} 3`,
+	}
+
+	metrics := operationMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// mongos has no metrics.operation section.
+	assert.Empty(t, operationMetrics(bson.M{"metrics": bson.M{}}, nil))
+}
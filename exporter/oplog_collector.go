@@ -0,0 +1,143 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type oplogCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *oplogCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *oplogCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *oplogCollector) Collect(ch chan<- prometheus.Metric) {
+	local := d.client.Database("local")
+
+	// Standalone instances don't have an oplog.
+	names, err := local.ListCollectionNames(d.ctx, bson.D{{Key: "name", Value: "oplog.rs"}})
+	if err != nil {
+		d.logger.Errorf("cannot list the collections in the local database: %s", err)
+		d.fail()
+
+		return
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	var stats bson.M
+
+	cmd := bson.D{{Key: "collStats", Value: "oplog.rs"}}
+	if err := local.RunCommand(d.ctx, cmd).Decode(&stats); err != nil {
+		d.logger.Errorf("cannot get collStats for local.oplog.rs: %s", err)
+		d.fail()
+
+		return
+	}
+
+	labels := d.topologyInfo.baseLabels()
+
+	for _, metric := range oplogSizeMetrics(stats, labels) {
+		ch <- metric
+	}
+
+	head, tail, err := oplogTimestamps(d.ctx, d.client)
+	if err != nil {
+		// An empty oplog has no window.
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			d.logger.Errorf("cannot get the oplog timestamps: %s", err)
+			d.fail()
+		}
+
+		return
+	}
+
+	ch <- oplogWindowMetric(head, tail, labels)
+}
+
+func oplogSizeMetrics(stats bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"maxSize"},
+			name: "mongodb_oplog_size_bytes",
+			help: "The configured maximum size of the oplog",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"size"},
+			name: "mongodb_oplog_used_bytes",
+			help: "The size of the data currently in the oplog",
+			vt:   prometheus.GaugeValue,
+		},
+	}
+
+	return fieldMetrics(stats, defs, labels)
+}
+
+func oplogWindowMetric(head, tail primitive.Timestamp, labels map[string]string) prometheus.Metric {
+	d := prometheus.NewDesc("mongodb_oplog_window_seconds",
+		"The time difference between the newest and the oldest entries in the oplog", nil, labels)
+
+	return prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(head.T)-float64(tail.T))
+}
+
+// oplogTimestamps returns the timestamps of the newest (head) and the oldest (tail) oplog entries.
+func oplogTimestamps(ctx context.Context, client *mongo.Client) (primitive.Timestamp, primitive.Timestamp, error) {
+	oplogRS := client.Database("local").Collection("oplog.rs")
+
+	type oplogRSResult struct {
+		Timestamp primitive.Timestamp `bson:"ts"`
+	}
+
+	var head, tail oplogRSResult
+
+	headOpts := options.FindOne().SetSort(bson.M{"$natural": -1})
+	if err := oplogRS.FindOne(ctx, bson.M{}, headOpts).Decode(&head); err != nil {
+		return primitive.Timestamp{}, primitive.Timestamp{}, err
+	}
+
+	tailOpts := options.FindOne().SetSort(bson.M{"$natural": 1})
+	if err := oplogRS.FindOne(ctx, bson.M{}, tailOpts).Decode(&tail); err != nil {
+		return primitive.Timestamp{}, primitive.Timestamp{}, err
+	}
+
+	return head.Timestamp, tail.Timestamp, nil
+}
+
+var _ prometheus.Collector = (*oplogCollector)(nil)
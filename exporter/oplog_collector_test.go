@@ -0,0 +1,84 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/percona/mongodb_exporter/internal/tu"
+)
+
+func TestOplogCollector(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	c := &oplogCollector{
+		ctx:          ctx,
+		client:       tu.DefaultTestClient(ctx, t),
+		logger:       logrus.New(),
+		topologyInfo: labelsGetterMock{},
+	}
+
+	metrics := helpers.ReadMetrics(helpers.CollectMetrics(c))
+	names := map[string]bool{}
+	for _, m := range metrics {
+		names[m.Name] = true
+	}
+
+	assert.True(t, names["mongodb_oplog_size_bytes"])
+	assert.True(t, names["mongodb_oplog_used_bytes"])
+	assert.True(t, names["mongodb_oplog_window_seconds"])
+
+	// A standalone instance has no oplog so, there are no metrics and no errors.
+	c.client = tu.TestClient(ctx, tu.MongoDBStandAlonePort, t)
+	assert.Empty(t, helpers.CollectMetrics(c))
+}
+
+func TestOplogMetrics(t *testing.T) {
+	stats := bson.M{
+		"ns":      "local.oplog.rs",
+		"size":    int32(4096),
+		"maxSize": int64(1073741824),
+	}
+
+	head := primitive.Timestamp{T: 1620000600, I: 1}
+	tail := primitive.Timestamp{T: 1620000000, I: 3}
+
+	want := []string{
+		"# HELP mongodb_oplog_size_bytes The configured maximum size of the oplog",
+		"# TYPE mongodb_oplog_size_bytes gauge",
+		"mongodb_oplog_size_bytes 1.073741824e+09",
+		"# HELP mongodb_oplog_used_bytes The size of the data currently in the oplog",
+		"# TYPE mongodb_oplog_used_bytes gauge",
+		"mongodb_oplog_used_bytes 4096",
+		"# HELP mongodb_oplog_window_seconds The time difference between the newest and the oldest entries in the oplog",
+		"# TYPE mongodb_oplog_window_seconds gauge",
+		"mongodb_oplog_window_seconds 600",
+	}
+
+	metrics := oplogSizeMetrics(stats, nil)
+	metrics = append(metrics, oplogWindowMetric(head, tail, nil))
+	assert.Equal(t, want, helpers.Format(metrics))
+}
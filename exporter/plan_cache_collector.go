@@ -0,0 +1,166 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// planCacheCollector exposes the plan cache entries of an allow-list of collections, from the
+// $planCacheStats aggregation stage (MongoDB 4.2+). There is no discovery since the plan cache is
+// only worth watching for the collections under query tuning.
+type planCacheCollector struct {
+	ctx         context.Context
+	client      *mongo.Client
+	collections []string
+	// If not nil, it counts the evictions across the scrapes. The /scrape targets don't have it,
+	// since it's indexed by namespace.
+	evictions    *planCacheEvictions
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *planCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *planCacheCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *planCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ns := range d.collections {
+		database, collection := splitNamespace(ns)
+		if collection == "" {
+			continue
+		}
+
+		keys, err := d.planCacheKeys(database, collection)
+		if err != nil {
+			// Views, and the servers before 4.2, have no plan cache stats.
+			d.logger.Debugf("cannot get $planCacheStats of %s: %s", ns, err)
+
+			continue
+		}
+
+		var evictions *float64
+
+		if d.evictions != nil {
+			count := d.evictions.update(ns, keys)
+			evictions = &count
+		}
+
+		for _, metric := range planCacheMetrics(ns, len(keys), evictions, d.topologyInfo.baseLabels()) {
+			ch <- metric
+		}
+	}
+}
+
+// planCacheKeys returns the keys of the cached plans of the collection. A collection that doesn't
+// exist, or whose queries have no cached plans, has none.
+func (d *planCacheCollector) planCacheKeys(database, collection string) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$planCacheStats", Value: bson.M{}}},
+		{{Key: "$project", Value: bson.M{"planCacheKey": 1, "queryHash": 1}}},
+	}
+
+	cursor, err := d.client.Database(database).Collection(collection).Aggregate(d.ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []bson.M
+	if err := cursor.All(d.ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		key, ok := entry["planCacheKey"].(string)
+		if !ok {
+			key, _ = entry["queryHash"].(string)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// planCacheMetrics returns the number of plan cache entries of the namespace and, if evictions is
+// not nil, the number of entries removed from the cache since the exporter started.
+func planCacheMetrics(ns string, entries int, evictions *float64, labels map[string]string) []prometheus.Metric {
+	labels["namespace"] = ns
+
+	d := prometheus.NewDesc("mongodb_plan_cache_entries", "Number of cached query plans of the collection", nil, labels)
+	metrics := []prometheus.Metric{prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(entries))}
+
+	if evictions != nil {
+		d = prometheus.NewDesc("mongodb_plan_cache_evictions_total",
+			"Number of cached query plans of the collection removed between the scrapes, by eviction or invalidation",
+			nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.CounterValue, *evictions))
+	}
+
+	return metrics
+}
+
+// planCacheEvictions counts the plan cache entries removed per namespace. MongoDB doesn't report the
+// evictions per collection, so they are found by comparing the cache keys of consecutive scrapes.
+// Like collStatsErrors, it's shared by the collectors of the exporter, since they only live for a scrape.
+type planCacheEvictions struct {
+	m      sync.Mutex
+	keys   map[string]map[string]bool
+	counts map[string]float64
+}
+
+func newPlanCacheEvictions() *planCacheEvictions {
+	return &planCacheEvictions{
+		keys:   make(map[string]map[string]bool),
+		counts: make(map[string]float64),
+	}
+}
+
+// update records the current cache keys of the namespace and returns its evictions count.
+func (c *planCacheEvictions) update(ns string, keys []string) float64 {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	current := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		current[key] = true
+	}
+
+	for key := range c.keys[ns] {
+		if !current[key] {
+			c.counts[ns]++
+		}
+	}
+
+	c.keys[ns] = current
+
+	return c.counts[ns]
+}
+
+var _ prometheus.Collector = (*planCacheCollector)(nil)
@@ -0,0 +1,52 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanCacheEvictions(t *testing.T) {
+	c := newPlanCacheEvictions()
+
+	assert.Equal(t, 0.0, c.update("testdb.users", []string{"A1", "B2", "C3"}))
+	assert.Equal(t, 2.0, c.update("testdb.users", []string{"C3", "D4"}))
+	assert.Equal(t, 0.0, c.update("testdb.orders", nil))
+	assert.Equal(t, 4.0, c.update("testdb.users", nil))
+}
+
+func TestPlanCacheMetrics(t *testing.T) {
+	want := []string{
+		"# HELP mongodb_plan_cache_entries Number of cached query plans of the collection",
+		"# TYPE mongodb_plan_cache_entries gauge",
+		`mongodb_plan_cache_entries{namespace="testdb.users",rs_nm="rs1"} 3`,
+		"# HELP mongodb_plan_cache_evictions_total Number of cached query plans of the collection removed between the scrapes, by eviction or invalidation",
+		"# TYPE mongodb_plan_cache_evictions_total counter",
+		`mongodb_plan_cache_evictions_total{namespace="testdb.users",rs_nm="rs1"} 2`,
+	}
+
+	evictions := 2.0
+	metrics := planCacheMetrics("testdb.users", 3, &evictions, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Without the evictions tracker, like in the /scrape targets.
+	metrics = planCacheMetrics("testdb.users", 3, nil, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want[:3], helpers.Format(metrics))
+}
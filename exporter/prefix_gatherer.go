@@ -0,0 +1,54 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const defaultMetricsPrefix = "mongodb"
+
+//nolint:gochecknoglobals
+var metricsPrefixRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// prefixGatherer replaces the mongodb prefix in the names of the gathered metrics. Doing it
+// here instead of in the collectors makes sure all the metrics, including the ones from the
+// compatible mode, are renamed consistently.
+type prefixGatherer struct {
+	gatherer prometheus.Gatherer
+	prefix   string
+}
+
+func (g *prefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	for _, family := range families {
+		name := family.GetName()
+		if strings.HasPrefix(name, defaultMetricsPrefix+"_") {
+			name = g.prefix + strings.TrimPrefix(name, defaultMetricsPrefix)
+			family.Name = &name
+		}
+	}
+
+	return families, err
+}
+
+var _ prometheus.Gatherer = (*prefixGatherer)(nil)
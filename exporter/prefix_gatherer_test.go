@@ -0,0 +1,54 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&fakeCollector{collect: func(ch chan<- prometheus.Metric) {
+		d := prometheus.NewDesc("mongodb_up", "Whether MongoDB is up.", nil, nil)
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, 1)
+
+		d = prometheus.NewDesc("mongodbx_other", "Not a mongodb_ metric", nil, nil)
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, 2)
+	}})
+
+	want := strings.NewReader(`# HELP mongodb_analytics_up Whether MongoDB is up.
+# TYPE mongodb_analytics_up gauge
+mongodb_analytics_up 1
+# HELP mongodbx_other Not a mongodb_ metric
+# TYPE mongodbx_other gauge
+mongodbx_other 2
+`)
+
+	err := testutil.GatherAndCompare(&prefixGatherer{gatherer: registry, prefix: "mongodb_analytics"}, want)
+	require.NoError(t, err)
+}
+
+func TestNewInvalidMetricsPrefix(t *testing.T) {
+	_, err := New(&Opts{MetricsPrefix: "mongo-db"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// profileCollector aggregates the slow queries recorded by the database profiler.
+type profileCollector struct {
+	collectFailure
+
+	ctx             context.Context
+	client          *mongo.Client
+	databases       []string
+	discoveringMode bool
+	logger          *logrus.Logger
+	topologyInfo    labelsGetter
+}
+
+func (d *profileCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *profileCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *profileCollector) Collect(ch chan<- prometheus.Metric) {
+	databases := d.databases
+
+	if len(databases) == 0 && d.discoveringMode {
+		dbNames, err := d.client.ListDatabaseNames(d.ctx, bson.D{})
+		if err != nil {
+			d.logger.Errorf("cannot get the database names list: %s", err)
+			d.fail()
+
+			return
+		}
+
+		databases = filterSystemDatabases(dbNames)
+	}
+
+	for _, database := range databases {
+		if database == "" {
+			continue
+		}
+
+		db := d.client.Database(database)
+
+		// The system.profile collection doesn't exist if profiling was never enabled.
+		names, err := db.ListCollectionNames(d.ctx, bson.D{{Key: "name", Value: "system.profile"}})
+		if err != nil {
+			d.logger.Errorf("cannot list the collections in database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		group := bson.D{
+			{Key: "$group", Value: bson.M{
+				"_id":    bson.M{"ns": "$ns", "op": "$op"},
+				"count":  bson.M{"$sum": 1},
+				"millis": bson.M{"$sum": "$millis"},
+			}},
+		}
+
+		cursor, err := db.Collection("system.profile").Aggregate(d.ctx, mongo.Pipeline{group})
+		if err != nil {
+			d.logger.Errorf("cannot aggregate system.profile for database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		var stats []bson.M
+		if err = cursor.All(d.ctx, &stats); err != nil {
+			d.logger.Errorf("cannot aggregate system.profile for database %s: %s", database, err)
+			d.fail()
+
+			continue
+		}
+
+		d.logger.Debugf("system.profile stats for %s", database)
+		debugResult(d.logger, stats)
+
+		for _, metric := range profileMetrics(stats, d.topologyInfo.baseLabels()) {
+			ch <- metric
+		}
+	}
+}
+
+// profileMetrics builds the metrics from the system.profile entries grouped by namespace and operation.
+// Since system.profile is a capped collection, they reflect the queries currently in it, so they are
+// gauges, not counters.
+func profileMetrics(stats []bson.M, labels map[string]string) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, 2*len(stats)) //nolint:gomnd
+
+	for _, group := range stats {
+		id, ok := group["_id"].(bson.M)
+		if !ok {
+			continue
+		}
+
+		ns, _ := id["ns"].(string)
+		db, collection := splitNamespace(ns)
+
+		groupLabels := make(map[string]string, len(labels)+3) //nolint:gomnd
+		for k, v := range labels {
+			groupLabels[k] = v
+		}
+
+		groupLabels["db"] = db
+		groupLabels["collection"] = collection
+		groupLabels["op"] = fmt.Sprintf("%v", id["op"])
+
+		if count, err := asFloat64(group["count"]); err == nil && count != nil {
+			d := prometheus.NewDesc("mongodb_profile_slow_queries",
+				"Number of slow queries currently recorded by the profiler in system.profile", nil, groupLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *count))
+		}
+
+		if millis, err := asFloat64(group["millis"]); err == nil && millis != nil {
+			d := prometheus.NewDesc("mongodb_profile_slow_queries_time_seconds",
+				"Time spent by the slow queries currently recorded by the profiler in system.profile", nil, groupLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *millis/1000)) //nolint:gomnd
+		}
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*profileCollector)(nil)
@@ -0,0 +1,53 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestProfileMetrics(t *testing.T) {
+	stats := []bson.M{
+		{
+			"_id":    bson.M{"ns": "testdb.testcol", "op": "query"},
+			"count":  int32(3),
+			"millis": int64(1500),
+		},
+		{
+			"_id":    bson.M{"ns": "testdb.system.views", "op": "update"},
+			"count":  int32(1),
+			"millis": int32(250),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_profile_slow_queries Number of slow queries currently recorded by the profiler in system.profile",
+		"# TYPE mongodb_profile_slow_queries gauge",
+		`mongodb_profile_slow_queries{collection="system.views",db="testdb",op="update"} 1`,
+		`mongodb_profile_slow_queries{collection="testcol",db="testdb",op="query"} 3`,
+		"# HELP mongodb_profile_slow_queries_time_seconds Time spent by the slow queries currently recorded by the profiler in system.profile",
+		"# TYPE mongodb_profile_slow_queries_time_seconds gauge",
+		`mongodb_profile_slow_queries_time_seconds{collection="system.views",db="testdb",op="update"} 0.25`,
+		`mongodb_profile_slow_queries_time_seconds{collection="testcol",db="testdb",op="query"} 1.5`,
+	}
+
+	assert.Equal(t, want, helpers.Format(profileMetrics(stats, map[string]string{})))
+}
@@ -0,0 +1,108 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+const (
+	defaultPushInterval = 30 * time.Second
+	pushJob             = "mongodb_exporter"
+)
+
+// pushLoop pushes the metrics to the Pushgateway every PushInterval, for the networks where
+// Prometheus cannot reach the exporter, until Shutdown.
+func (e *Exporter) pushLoop() {
+	interval := e.opts.PushInterval
+	if interval <= 0 {
+		interval = defaultPushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+
+		// Shutdown might have run while waiting for the ticker.
+		if e.isClosed() {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+
+		if err := e.push(ctx); err != nil {
+			e.logger.Errorf("Cannot push the metrics to the Pushgateway: %s", err)
+		}
+
+		cancel()
+	}
+}
+
+func (e *Exporter) push(ctx context.Context) error {
+	client, topologyInfo, release, err := e.scrapeClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	registry := e.makeRegistry(ctx, client, topologyInfo)
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, e.gatherer(registry)}
+
+	return e.pusher(topologyInfo, gatherers).Push()
+}
+
+// pusher returns the Pushgateway pusher grouped by the MongoDB hosts, so the exporters of different
+// instances don't replace each other metrics, and by the topology labels. The replica set state is
+// not used since every change would leave a stale group in the Pushgateway.
+func (e *Exporter) pusher(topologyInfo labelsGetter, g prometheus.Gatherer) *push.Pusher {
+	pusher := push.New(e.opts.PushGatewayURL, pushJob).Gatherer(g)
+
+	if instance := pushInstance(e.opts.URI); instance != "" {
+		pusher = pusher.Grouping("instance", instance)
+	}
+
+	for name, value := range topologyInfo.baseLabels() {
+		if name != labelReplicasetState && value != "" {
+			pusher = pusher.Grouping(name, value)
+		}
+	}
+
+	return pusher
+}
+
+// pushInstance returns the hosts of the URI, without the credentials.
+func pushInstance(uri string) string {
+	cs, err := connstring.Parse(uri)
+	if err != nil {
+		return ""
+	}
+
+	return strings.Join(cs.Hosts, ",")
+}
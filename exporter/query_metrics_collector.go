@@ -0,0 +1,102 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// queryMetricsCollector exposes serverStatus().metrics.queryExecutor and serverStatus().metrics.document
+// to help detecting queries not using indexes.
+type queryMetricsCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *queryMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *queryMetricsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *queryMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range queryMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+func queryMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"metrics", "queryExecutor", "scanned"},
+			name: "mongodb_metrics_query_executor_scanned_total",
+			help: "Number of index items scanned during queries and query-plan evaluation",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "queryExecutor", "scannedObjects"},
+			name: "mongodb_metrics_query_executor_scanned_objects_total",
+			help: "Number of documents scanned during queries and query-plan evaluation",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			// Available since MongoDB 4.4.
+			path: []string{"metrics", "queryExecutor", "collectionScans", "total"},
+			name: "mongodb_metrics_query_executor_collection_scans_total",
+			help: "Number of queries that performed a collection scan",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "queryExecutor", "collectionScans", "nonTailable"},
+			name: "mongodb_metrics_query_executor_collection_scans_non_tailable_total",
+			help: "Number of queries that performed a collection scan that did not use a tailable cursor",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	for _, state := range []string{"deleted", "inserted", "returned", "updated"} {
+		defs = append(defs, fieldMetric{
+			path:   []string{"metrics", "document", state},
+			name:   "mongodb_metrics_document_total",
+			help:   "Number of documents deleted, inserted, returned or updated",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"state": state},
+		})
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*queryMetricsCollector)(nil)
@@ -0,0 +1,63 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	m := bson.M{
+		"metrics": bson.M{
+			"queryExecutor": bson.M{
+				"scanned":        int64(1200),
+				"scannedObjects": int64(3400),
+			},
+			"document": bson.M{
+				"deleted":  int64(1),
+				"inserted": int64(20),
+				"returned": int64(300),
+				"updated":  int64(4),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_metrics_document_total Number of documents deleted, inserted, returned or updated",
+		"# TYPE mongodb_metrics_document_total counter",
+		`mongodb_metrics_document_total{rs_nm="rs1",state="deleted"} 1`,
+		`mongodb_metrics_document_total{rs_nm="rs1",state="inserted"} 20`,
+		`mongodb_metrics_document_total{rs_nm="rs1",state="returned"} 300`,
+		`mongodb_metrics_document_total{rs_nm="rs1",state="updated"} 4`,
+		"# HELP mongodb_metrics_query_executor_scanned_objects_total Number of documents scanned during queries and query-plan evaluation",
+		"# TYPE mongodb_metrics_query_executor_scanned_objects_total counter",
+		`mongodb_metrics_query_executor_scanned_objects_total{rs_nm="rs1"} 3400`,
+		"# HELP mongodb_metrics_query_executor_scanned_total Number of index items scanned during queries and query-plan evaluation",
+		"# TYPE mongodb_metrics_query_executor_scanned_total counter",
+		`mongodb_metrics_query_executor_scanned_total{rs_nm="rs1"} 1200`,
+	}
+
+	metrics := queryMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Missing sections are skipped.
+	assert.Empty(t, queryMetrics(bson.M{"metrics": bson.M{}}, nil))
+}
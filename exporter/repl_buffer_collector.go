@@ -0,0 +1,99 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// replBufferCollector exposes the oplog buffer and the oplog application metrics from
+// serverStatus().metrics.repl. The secondaries fetch the oplog entries into the buffer and apply
+// them in batches. There is no replication in mongos.
+type replBufferCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *replBufferCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *replBufferCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *replBufferCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range replBufferMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// replBufferMetrics returns no metrics for the servers without the metrics.repl section.
+func replBufferMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"metrics", "repl", "buffer", "count"},
+			name: "mongodb_repl_buffer_count",
+			help: "Number of oplog entries in the oplog buffer",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"metrics", "repl", "buffer", "sizeBytes"},
+			name: "mongodb_repl_buffer_size_bytes",
+			help: "Size of the oplog buffer",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path: []string{"metrics", "repl", "apply", "batches", "num"},
+			name: "mongodb_repl_apply_batches_total",
+			help: "Number of oplog batches applied",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "repl", "apply", "batches", "totalMillis"},
+			name: "mongodb_repl_apply_batches_millis_total",
+			help: "Total time spent applying the oplog batches, in milliseconds",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "repl", "apply", "ops"},
+			name: "mongodb_repl_apply_ops_total",
+			help: "Number of oplog operations applied",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*replBufferCollector)(nil)
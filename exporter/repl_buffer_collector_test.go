@@ -0,0 +1,67 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReplBufferMetrics(t *testing.T) {
+	m := bson.M{
+		"metrics": bson.M{
+			"repl": bson.M{
+				"apply": bson.M{
+					"attemptsToBecomeSecondary": int64(1),
+					"batches":                   bson.M{"num": int64(42), "totalMillis": int64(17)},
+					"ops":                       int64(120),
+				},
+				"buffer": bson.M{
+					"count":        int64(3),
+					"maxSizeBytes": int64(268435456),
+					"sizeBytes":    int64(512),
+				},
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_repl_apply_batches_millis_total Total time spent applying the oplog batches, in milliseconds",
+		"# TYPE mongodb_repl_apply_batches_millis_total counter",
+		`mongodb_repl_apply_batches_millis_total{rs_nm="rs1"} 17`,
+		"# HELP mongodb_repl_apply_batches_total Number of oplog batches applied",
+		"# TYPE mongodb_repl_apply_batches_total counter",
+		`mongodb_repl_apply_batches_total{rs_nm="rs1"} 42`,
+		"# HELP mongodb_repl_apply_ops_total Number of oplog operations applied",
+		"# TYPE mongodb_repl_apply_ops_total counter",
+		`mongodb_repl_apply_ops_total{rs_nm="rs1"} 120`,
+		"# HELP mongodb_repl_buffer_count Number of oplog entries in the oplog buffer",
+		"# TYPE mongodb_repl_buffer_count gauge",
+		`mongodb_repl_buffer_count{rs_nm="rs1"} 3`,
+		"# HELP mongodb_repl_buffer_size_bytes Size of the oplog buffer",
+		"# TYPE mongodb_repl_buffer_size_bytes gauge",
+		`mongodb_repl_buffer_size_bytes{rs_nm="rs1"} 512`,
+	}
+
+	metrics := replBufferMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	assert.Empty(t, replBufferMetrics(bson.M{"ok": float64(1)}, nil))
+}
@@ -18,10 +18,12 @@ package exporter
 
 import (
 	"context"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -31,9 +33,13 @@ const (
 )
 
 type replSetGetStatusCollector struct {
+	collectFailure
+
 	ctx            context.Context
 	client         *mongo.Client
 	compatibleMode bool
+	remaps         *compatibleRemaps
+	localNodeOnly  bool
 	logger         *logrus.Logger
 	topologyInfo   labelsGetter
 }
@@ -42,6 +48,10 @@ func (d *replSetGetStatusCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
+func (d *replSetGetStatusCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
 func (d *replSetGetStatusCollector) Collect(ch chan<- prometheus.Metric) {
 	cmd := bson.D{{Key: "replSetGetStatus", Value: "1"}}
 	res := d.client.Database("admin").RunCommand(d.ctx, cmd)
@@ -55,6 +65,7 @@ func (d *replSetGetStatusCollector) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 		d.logger.Errorf("cannot get replSetGetStatus: %s", err)
+		d.fail()
 
 		return
 	}
@@ -62,9 +73,290 @@ func (d *replSetGetStatusCollector) Collect(ch chan<- prometheus.Metric) {
 	d.logger.Debug("replSetGetStatus result:")
 	debugResult(d.logger, m)
 
-	for _, metric := range makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode) {
+	// The lag needs the primary optime, so it is computed before dropping the other members.
+	for _, metric := range replicationLagMetrics(m, d.topologyInfo.baseLabels(), d.localNodeOnly) {
+		ch <- metric
+	}
+
+	for _, metric := range heartbeatMetrics(m, d.topologyInfo.baseLabels(), d.localNodeOnly) {
+		ch <- metric
+	}
+
+	for _, metric := range memberUptimeMetrics(m, d.topologyInfo.baseLabels(), d.localNodeOnly) {
+		ch <- metric
+	}
+
+	for _, metric := range memberCountMetrics(m, d.getConfig(), d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+
+	if d.localNodeOnly {
+		m = localMemberStatus(m)
+	}
+
+	for _, metric := range makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode, d.remaps) {
 		ch <- metric
 	}
 }
 
+// getConfig returns the replica set config, or nil if the user cannot run replSetGetConfig.
+func (d *replSetGetStatusCollector) getConfig() bson.M {
+	var res struct {
+		Config bson.M `bson:"config"`
+	}
+
+	cmd := bson.D{{Key: "replSetGetConfig", Value: 1}}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&res); err != nil {
+		d.logger.Debugf("cannot get replSetGetConfig, counting the members from replSetGetStatus: %s", err)
+
+		return nil
+	}
+
+	return res.Config
+}
+
+// localMemberStatus returns a copy of the replSetGetStatus result where the members list only
+// has the member this exporter is connected to.
+func localMemberStatus(m bson.M) bson.M {
+	res := make(bson.M, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+
+	members, ok := m["members"].(primitive.A)
+	if !ok {
+		return res
+	}
+
+	local := primitive.A{}
+
+	for _, member := range members {
+		if mm, ok := member.(bson.M); ok && isSelfMember(mm) {
+			local = append(local, mm)
+		}
+	}
+
+	res["members"] = local
+
+	return res
+}
+
+func isSelfMember(m bson.M) bool {
+	self, _ := m["self"].(bool)
+
+	return self
+}
+
+// replicationLagMetrics computes the lag of each member as the difference between the primary optime
+// and the member optime. If there is no primary visible from this node, there are no lag metrics.
+// If localOnly is set, only the lag of the member this exporter is connected to is reported.
+func replicationLagMetrics(m bson.M, labels map[string]string, localOnly bool) []prometheus.Metric {
+	members, ok := m["members"].(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	var primaryOptime time.Time
+
+	for _, member := range members {
+		mm, ok := member.(bson.M)
+		if !ok {
+			continue
+		}
+
+		if state, _ := mm["stateStr"].(string); state == "PRIMARY" {
+			if optime, ok := mm["optimeDate"].(primitive.DateTime); ok {
+				primaryOptime = optime.Time()
+			}
+
+			break
+		}
+	}
+
+	if primaryOptime.IsZero() {
+		return nil
+	}
+
+	metrics := make([]prometheus.Metric, 0, len(members))
+
+	for _, member := range members {
+		mm, ok := member.(bson.M)
+		if !ok {
+			continue
+		}
+
+		if localOnly && !isSelfMember(mm) {
+			continue
+		}
+
+		// Arbiters don't have data, so they don't have an optime.
+		optime, ok := mm["optimeDate"].(primitive.DateTime)
+		if !ok || optime <= 0 {
+			continue
+		}
+
+		lag := primaryOptime.Sub(optime.Time()).Seconds()
+		if lag < 0 {
+			// The primary optime in the status might be older than the member optime.
+			lag = 0
+		}
+
+		memberLabels := make(map[string]string, len(labels)+2) //nolint:gomnd
+		for k, v := range labels {
+			memberLabels[k] = v
+		}
+
+		memberLabels["name"], _ = mm["name"].(string)
+		memberLabels["state"], _ = mm["stateStr"].(string)
+
+		d := prometheus.NewDesc("mongodb_replset_member_replication_lag_seconds",
+			"Difference between the primary optime and the member optime", nil, memberLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, lag))
+	}
+
+	return metrics
+}
+
+// heartbeatMetrics returns the election term of the set and, for each member, the last heartbeat
+// received from it and the round trip time of the heartbeats. The member this exporter is
+// connected to has no heartbeat metrics, like the members that have not answered a heartbeat yet.
+func heartbeatMetrics(m bson.M, labels map[string]string, localOnly bool) []prometheus.Metric {
+	var metrics []prometheus.Metric
+
+	if term, err := asFloat64(m["term"]); err == nil && term != nil {
+		d := prometheus.NewDesc("mongodb_replset_term", "Election term of the replica set, incremented by every election",
+			nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *term))
+	}
+
+	members, _ := m["members"].(primitive.A)
+
+	for _, member := range members {
+		mm, ok := member.(bson.M)
+		if !ok || (localOnly && !isSelfMember(mm)) {
+			continue
+		}
+
+		// Without heartbeats, the date is the Unix epoch and the ping time is zero.
+		recv, ok := mm["lastHeartbeatRecv"].(primitive.DateTime)
+		if !ok || recv <= 0 {
+			continue
+		}
+
+		memberLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			memberLabels[k] = v
+		}
+
+		memberLabels["name"], _ = mm["name"].(string)
+
+		d := prometheus.NewDesc("mongodb_replset_member_last_heartbeat_seconds",
+			"Unix time of the last heartbeat received from the member", nil, memberLabels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue,
+			float64(recv.Time().UnixNano())/float64(time.Second)))
+
+		if ping, err := asFloat64(mm["pingMs"]); err == nil && ping != nil {
+			d := prometheus.NewDesc("mongodb_replset_member_ping_ms",
+				"Round trip time of the heartbeats to the member, in milliseconds", nil, memberLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *ping))
+		}
+	}
+
+	return metrics
+}
+
+// memberUptimeMetrics returns the uptime and the optime date of each member. The members that are
+// not reachable have no uptime, and their optime date is the Unix epoch, so they are skipped.
+func memberUptimeMetrics(m bson.M, labels map[string]string, localOnly bool) []prometheus.Metric {
+	members, ok := m["members"].(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	metrics := make([]prometheus.Metric, 0, 2*len(members)) //nolint:gomnd
+
+	for _, member := range members {
+		mm, ok := member.(bson.M)
+		if !ok || (localOnly && !isSelfMember(mm)) {
+			continue
+		}
+
+		nameLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			nameLabels[k] = v
+		}
+
+		nameLabels["name"], _ = mm["name"].(string)
+
+		if uptime, err := asFloat64(mm["uptime"]); err == nil && uptime != nil {
+			stateLabels := make(map[string]string, len(nameLabels)+1)
+			for k, v := range nameLabels {
+				stateLabels[k] = v
+			}
+
+			stateLabels["state"], _ = mm["stateStr"].(string)
+
+			d := prometheus.NewDesc("mongodb_replset_member_uptime_seconds",
+				"Seconds the member has been up, as seen by the connected member", nil, stateLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *uptime))
+		}
+
+		if optime, ok := mm["optimeDate"].(primitive.DateTime); ok && optime > 0 {
+			d := prometheus.NewDesc("mongodb_replset_member_optime_date_seconds",
+				"Unix time of the last operation applied by the member", nil, nameLabels)
+			metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue,
+				float64(optime.Time().UnixNano())/float64(time.Second)))
+		}
+	}
+
+	return metrics
+}
+
+// memberCountMetrics returns the number of members and of voting members from the replica set config.
+// Without the config, the members are counted from the status, and the voting members are taken
+// from votingMembersCount, only available since MongoDB 4.4.
+func memberCountMetrics(status, config bson.M, labels map[string]string) []prometheus.Metric {
+	var members, voting *float64
+
+	if configMembers, ok := config["members"].(primitive.A); ok {
+		n, v := float64(len(configMembers)), 0.0
+
+		for _, member := range configMembers {
+			mm, ok := member.(bson.M)
+			if !ok {
+				continue
+			}
+
+			if votes, err := asFloat64(mm["votes"]); err == nil && votes != nil && *votes > 0 {
+				v++
+			}
+		}
+
+		members, voting = &n, &v
+	} else if statusMembers, ok := status["members"].(primitive.A); ok {
+		n := float64(len(statusMembers))
+		members = &n
+
+		if v, err := asFloat64(status["votingMembersCount"]); err == nil {
+			voting = v
+		}
+	}
+
+	var metrics []prometheus.Metric
+
+	if members != nil {
+		d := prometheus.NewDesc("mongodb_replset_number_of_members", "Number of members in the replica set",
+			nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *members))
+	}
+
+	if voting != nil {
+		d := prometheus.NewDesc("mongodb_replset_number_of_voting_members",
+			"Number of voting members in the replica set", nil, labels)
+		metrics = append(metrics, prometheus.MustNewConstMetric(d, prometheus.GaugeValue, *voting))
+	}
+
+	return metrics
+}
+
 var _ prometheus.Collector = (*replSetGetStatusCollector)(nil)
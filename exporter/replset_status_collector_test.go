@@ -22,9 +22,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/percona/exporter_shared/helpers"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/percona/mongodb_exporter/internal/tu"
 )
@@ -82,3 +85,198 @@ func TestReplsetStatusCollectorNoSharding(t *testing.T) {
 	err := testutil.CollectAndCompare(c, expected)
 	assert.NoError(t, err)
 }
+
+func TestReplicationLagMetrics(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	status := bson.M{
+		"members": primitive.A{
+			bson.M{"name": "127.0.0.1:17001", "stateStr": "PRIMARY", "optimeDate": primitive.NewDateTimeFromTime(now)},
+			bson.M{"name": "127.0.0.1:17002", "stateStr": "SECONDARY", "optimeDate": primitive.NewDateTimeFromTime(now.Add(-5 * time.Second))},
+			bson.M{"name": "127.0.0.1:17003", "stateStr": "ARBITER"},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_replset_member_replication_lag_seconds Difference between the primary optime and the member optime",
+		"# TYPE mongodb_replset_member_replication_lag_seconds gauge",
+		`mongodb_replset_member_replication_lag_seconds{name="127.0.0.1:17001",rs_nm="rs1",state="PRIMARY"} 0`,
+		`mongodb_replset_member_replication_lag_seconds{name="127.0.0.1:17002",rs_nm="rs1",state="SECONDARY"} 5`,
+	}
+
+	metrics := replicationLagMetrics(status, map[string]string{labelReplicasetName: "rs1"}, false)
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Without a visible primary, the lag cannot be computed.
+	status = bson.M{
+		"members": primitive.A{
+			bson.M{"name": "127.0.0.1:17002", "stateStr": "SECONDARY", "optimeDate": primitive.NewDateTimeFromTime(now)},
+		},
+	}
+	assert.Empty(t, replicationLagMetrics(status, nil, false))
+}
+
+func TestLocalNodeOnly(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	status := bson.M{
+		"set": "rs1",
+		"members": primitive.A{
+			bson.M{"name": "127.0.0.1:17001", "stateStr": "PRIMARY", "optimeDate": primitive.NewDateTimeFromTime(now)},
+			bson.M{"name": "127.0.0.1:17002", "stateStr": "SECONDARY", "optimeDate": primitive.NewDateTimeFromTime(now.Add(-5 * time.Second)), "self": true},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_replset_member_replication_lag_seconds Difference between the primary optime and the member optime",
+		"# TYPE mongodb_replset_member_replication_lag_seconds gauge",
+		`mongodb_replset_member_replication_lag_seconds{name="127.0.0.1:17002",rs_nm="rs1",state="SECONDARY"} 5`,
+	}
+
+	metrics := replicationLagMetrics(status, map[string]string{labelReplicasetName: "rs1"}, true)
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	local := localMemberStatus(status)
+	assert.Equal(t, "rs1", local["set"])
+	assert.Equal(t, primitive.A{status["members"].(primitive.A)[1]}, local["members"])
+	assert.Len(t, status["members"], 2)
+}
+
+func TestHeartbeatMetrics(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	status := bson.M{
+		"set":  "rs1",
+		"term": int64(3),
+		"members": primitive.A{
+			bson.M{"name": "127.0.0.1:17001", "stateStr": "PRIMARY", "self": true},
+			bson.M{
+				"name":              "127.0.0.1:17002",
+				"stateStr":          "SECONDARY",
+				"lastHeartbeatRecv": primitive.NewDateTimeFromTime(now),
+				"pingMs":            int64(1),
+			},
+			// It has not answered a heartbeat yet.
+			bson.M{
+				"name":              "127.0.0.1:17003",
+				"stateStr":          "(not reachable/healthy)",
+				"lastHeartbeatRecv": primitive.NewDateTimeFromTime(time.Unix(0, 0)),
+				"pingMs":            int64(0),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_replset_member_last_heartbeat_seconds Unix time of the last heartbeat received from the member",
+		"# TYPE mongodb_replset_member_last_heartbeat_seconds gauge",
+		`mongodb_replset_member_last_heartbeat_seconds{name="127.0.0.1:17002",rs_nm="rs1"} 1.6145928e+09`,
+		"# HELP mongodb_replset_member_ping_ms Round trip time of the heartbeats to the member, in milliseconds",
+		"# TYPE mongodb_replset_member_ping_ms gauge",
+		`mongodb_replset_member_ping_ms{name="127.0.0.1:17002",rs_nm="rs1"} 1`,
+		"# HELP mongodb_replset_term Election term of the replica set, incremented by every election",
+		"# TYPE mongodb_replset_term gauge",
+		`mongodb_replset_term{rs_nm="rs1"} 3`,
+	}
+
+	metrics := heartbeatMetrics(status, map[string]string{labelReplicasetName: "rs1"}, false)
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// The connected member has no heartbeat metrics.
+	metrics = heartbeatMetrics(status, map[string]string{labelReplicasetName: "rs1"}, true)
+	assert.Len(t, metrics, 1)
+}
+
+func TestMemberUptimeMetrics(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	status := bson.M{
+		"set": "rs1",
+		"members": primitive.A{
+			bson.M{
+				"name":       "127.0.0.1:17001",
+				"stateStr":   "PRIMARY",
+				"self":       true,
+				"uptime":     int64(120),
+				"optimeDate": primitive.NewDateTimeFromTime(now),
+			},
+			bson.M{
+				"name":       "127.0.0.1:17002",
+				"stateStr":   "SECONDARY",
+				"uptime":     int64(60),
+				"optimeDate": primitive.NewDateTimeFromTime(now.Add(-time.Second)),
+			},
+			bson.M{
+				"name":       "127.0.0.1:17003",
+				"stateStr":   "(not reachable/healthy)",
+				"optimeDate": primitive.NewDateTimeFromTime(time.Unix(0, 0)),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_replset_member_optime_date_seconds Unix time of the last operation applied by the member",
+		"# TYPE mongodb_replset_member_optime_date_seconds gauge",
+		`mongodb_replset_member_optime_date_seconds{name="127.0.0.1:17001",rs_nm="rs1"} 1.6145928e+09`,
+		`mongodb_replset_member_optime_date_seconds{name="127.0.0.1:17002",rs_nm="rs1"} 1.614592799e+09`,
+		"# HELP mongodb_replset_member_uptime_seconds Seconds the member has been up, as seen by the connected member",
+		"# TYPE mongodb_replset_member_uptime_seconds gauge",
+		`mongodb_replset_member_uptime_seconds{name="127.0.0.1:17001",rs_nm="rs1",state="PRIMARY"} 120`,
+		`mongodb_replset_member_uptime_seconds{name="127.0.0.1:17002",rs_nm="rs1",state="SECONDARY"} 60`,
+	}
+
+	metrics := memberUptimeMetrics(status, map[string]string{labelReplicasetName: "rs1"}, false)
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	metrics = memberUptimeMetrics(status, map[string]string{labelReplicasetName: "rs1"}, true)
+	assert.Len(t, metrics, 2)
+}
+
+func TestMemberCountMetrics(t *testing.T) {
+	status := bson.M{
+		"set":                "rs1",
+		"votingMembersCount": int32(2),
+		"members": primitive.A{
+			bson.M{"name": "127.0.0.1:17001"},
+			bson.M{"name": "127.0.0.1:17002"},
+			bson.M{"name": "127.0.0.1:17003"},
+		},
+	}
+	config := bson.M{
+		"_id": "rs1",
+		"members": primitive.A{
+			bson.M{"host": "127.0.0.1:17001", "votes": int32(1)},
+			bson.M{"host": "127.0.0.1:17002", "votes": int32(1)},
+			bson.M{"host": "127.0.0.1:17003", "votes": int32(1)},
+			bson.M{"host": "127.0.0.1:17004", "votes": int32(0), "hidden": true},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_replset_number_of_members Number of members in the replica set",
+		"# TYPE mongodb_replset_number_of_members gauge",
+		`mongodb_replset_number_of_members{rs_nm="rs1"} 4`,
+		"# HELP mongodb_replset_number_of_voting_members Number of voting members in the replica set",
+		"# TYPE mongodb_replset_number_of_voting_members gauge",
+		`mongodb_replset_number_of_voting_members{rs_nm="rs1"} 3`,
+	}
+
+	metrics := memberCountMetrics(status, config, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Without the config, the members in the status are counted.
+	want = []string{
+		"# HELP mongodb_replset_number_of_members Number of members in the replica set",
+		"# TYPE mongodb_replset_number_of_members gauge",
+		`mongodb_replset_number_of_members{rs_nm="rs1"} 3`,
+		"# HELP mongodb_replset_number_of_voting_members Number of voting members in the replica set",
+		"# TYPE mongodb_replset_number_of_voting_members gauge",
+		`mongodb_replset_number_of_voting_members{rs_nm="rs1"} 2`,
+	}
+
+	metrics = memberCountMetrics(status, nil, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Before MongoDB 4.4, there is no votingMembersCount in the status.
+	delete(status, "votingMembersCount")
+	assert.Len(t, memberCountMetrics(status, nil, nil), 1)
+}
@@ -0,0 +1,251 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/percona/exporter_shared"
+	"github.com/pkg/errors"
+)
+
+const defaultReadinessTimeout = 2 * time.Second
+
+//nolint:gochecknoglobals
+var landingPage = template.Must(template.New("home").Parse(strings.TrimSpace(`
+<html>
+<head>
+	<title>MongoDB exporter</title>
+</head>
+<body>
+	<h1>MongoDB exporter</h1>
+	<p><a href="{{ .path }}">Metrics</a></p>
+</body>
+</html>
+`)))
+
+// serveMux returns the handler for all the exporter endpoints. Only the metrics endpoint
+// is protected by the basic authentication so, the health checks work without credentials.
+func (e *Exporter) serveMux() (*http.ServeMux, error) {
+	metricsHandler := e.handler()
+
+	user, password, err := e.basicAuthCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if user != "" {
+		metricsHandler = basicAuthHandler(user, password, metricsHandler)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(e.path, metricsHandler)
+
+	if e.opts.MultiTarget {
+		scrapeHandler := e.scrapeHandler()
+		if user != "" {
+			scrapeHandler = basicAuthHandler(user, password, scrapeHandler)
+		}
+
+		mux.Handle("/scrape", scrapeHandler)
+	}
+
+	mux.Handle("/healthz", healthHandler())
+	mux.Handle("/ready", e.readyHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// The mux sends the unknown paths here too.
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if err := landingPage.Execute(w, map[string]string{"path": e.path}); err != nil {
+			e.logger.Errorf("cannot render the landing page: %s", err)
+		}
+	})
+
+	return mux, nil
+}
+
+// hstsHandler adds the Strict-Transport-Security header to the responses served over HTTPS, like
+// the exporter_shared server did.
+func hstsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthHandler reports the exporter is up, without connecting to MongoDB.
+func healthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	})
+}
+
+// readyHandler reports whether MongoDB is reachable. It pings the global client or, if there is
+// no global connection pool, it opens a new connection like the metrics handler does.
+func (e *Exporter) readyHandler() http.Handler {
+	timeout := e.opts.ReadinessTimeout
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		err := e.ping(ctx)
+		if errors.Is(err, errExporterClosed) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if err != nil {
+			e.logger.Warnf("MongoDB is not ready: %s", err)
+			http.Error(w, "MongoDB is not reachable: "+err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		fmt.Fprintln(w, "OK")
+	})
+}
+
+// ping fails without connecting once the exporter is shut down.
+func (e *Exporter) ping(ctx context.Context) error {
+	e.clientMu.Lock()
+	client, closed := e.client, e.closed
+	e.clientMu.Unlock()
+
+	if closed {
+		return errExporterClosed
+	}
+
+	if client != nil {
+		return client.Ping(ctx, nil)
+	}
+
+	// connect already pings the server.
+	client, err := connect(ctx, e.opts.URI, e.opts)
+	if err != nil {
+		return err
+	}
+
+	return client.Disconnect(ctx)
+}
+
+// basicAuthCredentials returns the basic authentication user and password from the options or,
+// if they are not set, from the HTTP_AUTH environment variable.
+func (e *Exporter) basicAuthCredentials() (string, string, error) {
+	if e.opts.WebAuthUsername == "" && e.opts.WebAuthPassword == "" {
+		return basicAuthFromEnv()
+	}
+
+	if e.opts.WebAuthUsername == "" || e.opts.WebAuthPassword == "" {
+		return "", "", errors.New("both the web authentication username and password must be set")
+	}
+
+	return e.opts.WebAuthUsername, e.opts.WebAuthPassword, nil
+}
+
+// basicAuthFromEnv reads the user:password from the HTTP_AUTH environment variable, if set.
+func basicAuthFromEnv() (string, string, error) {
+	httpAuth := os.Getenv("HTTP_AUTH")
+	if httpAuth == "" {
+		return "", "", nil
+	}
+
+	data := strings.SplitN(httpAuth, ":", 2) //nolint:gomnd
+	if len(data) != 2 || data[0] == "" || data[1] == "" {
+		return "", "", errors.New("HTTP_AUTH should be formatted as user:password")
+	}
+
+	return data[0], data[1], nil
+}
+
+func basicAuthHandler(user, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, _ := r.BasicAuth()
+		userOk := subtle.ConstantTimeCompare([]byte(user), []byte(u)) == 1
+		passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(p)) == 1
+
+		if !userOk || !passwordOk {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webTLSConfig returns the TLS config of the exporter HTTP server, or nil to serve plain HTTP. It has
+// the exporter_shared protocol version and cipher suites. With a client CA file, the clients must
+// present a certificate signed by one of those CAs.
+func webTLSConfig(opts *Opts) (*tls.Config, error) {
+	if opts.WebTLSCertFile == "" && opts.WebTLSKeyFile == "" {
+		if opts.WebTLSClientCAFile != "" {
+			return nil, errors.New("the web TLS client CA file needs the web TLS certificate and key files")
+		}
+
+		return nil, nil
+	}
+
+	if opts.WebTLSCertFile == "" || opts.WebTLSKeyFile == "" {
+		return nil, errors.New("both the web TLS certificate and key files must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.WebTLSCertFile, opts.WebTLSKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load the web TLS certificate and key")
+	}
+
+	config := exporter_shared.TLSConfig()
+	config.Certificates = []tls.Certificate{cert}
+
+	if opts.WebTLSClientCAFile != "" {
+		pem, err := ioutil.ReadFile(filepath.Clean(opts.WebTLSClientCAFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read the web TLS client CA file")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Wrapf(errInvalidCAFile, "web TLS client CA file %s", opts.WebTLSClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -0,0 +1,202 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/percona/exporter_shared"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/mongodb_exporter/internal/tu"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	e := &Exporter{
+		path:   "/metrics",
+		logger: logrus.New(),
+		opts: &Opts{
+			// Nothing listens on this port.
+			URI:              "mongodb://127.0.0.1:1",
+			DirectConnect:    true,
+			ReadinessTimeout: 100 * time.Millisecond,
+		},
+	}
+
+	mux, err := e.serveMux()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	start := time.Now()
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<a href="/metrics">`)
+
+	// Not ready after Shutdown, without connecting.
+	require.NoError(t, e.Shutdown(context.Background()))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), errExporterClosed.Error())
+
+	// Only the root path is the landing page.
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metricz", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHSTSHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	hstsHandler(healthHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "max-age=63072000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+}
+
+func TestMetricsBasicAuth(t *testing.T) {
+	os.Setenv("HTTP_AUTH", "user:pass") //nolint:errcheck
+	defer os.Unsetenv("HTTP_AUTH")      //nolint:errcheck
+
+	e := &Exporter{
+		path:   "/metrics",
+		logger: logrus.New(),
+		opts:   &Opts{},
+	}
+
+	mux, err := e.serveMux()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The health check doesn't need credentials.
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	os.Setenv("HTTP_AUTH", "user") //nolint:errcheck
+
+	_, err = e.serveMux()
+	assert.Error(t, err)
+}
+
+func TestMetricsBasicAuthOptions(t *testing.T) {
+	os.Setenv("HTTP_AUTH", "other:secret") //nolint:errcheck
+	defer os.Unsetenv("HTTP_AUTH")         //nolint:errcheck
+
+	e := &Exporter{
+		path:   "/metrics",
+		logger: logrus.New(),
+		opts:   &Opts{WebAuthUsername: "user", WebAuthPassword: "pass"},
+	}
+
+	mux, err := e.serveMux()
+	require.NoError(t, err)
+
+	// The options take precedence over HTTP_AUTH.
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("other", "secret")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	e.opts.WebAuthPassword = ""
+
+	_, err = e.serveMux()
+	assert.Error(t, err)
+}
+
+func TestWebTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM := tu.SelfSignedCertificate(t)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, certPEM, 0o600))
+
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0o600))
+
+	config, err := webTLSConfig(&Opts{})
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = webTLSConfig(&Opts{WebTLSCertFile: certFile})
+	assert.Error(t, err)
+
+	_, err = webTLSConfig(&Opts{WebTLSClientCAFile: certFile})
+	assert.Error(t, err)
+
+	// The self-signed certificate is also the CA of the client certificate.
+	config, err = webTLSConfig(&Opts{WebTLSCertFile: certFile, WebTLSKeyFile: keyFile, WebTLSClientCAFile: certFile})
+	require.NoError(t, err)
+
+	// The exporter_shared settings.
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, exporter_shared.TLSConfig().CipherSuites, config.CipherSuites)
+
+	ts := httptest.NewUnstartedServer(healthHandler())
+	ts.TLS = config
+	ts.StartTLS()
+
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(certPEM))
+
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}} //nolint:gosec
+
+	_, err = client.Get(ts.URL) //nolint:noctx
+	assert.Error(t, err, "a client without certificate must be rejected")
+
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{ //nolint:gosec
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}
+	res, err := client.Get(ts.URL) //nolint:noctx
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NoError(t, res.Body.Close())
+}
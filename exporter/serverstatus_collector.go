@@ -18,6 +18,7 @@ package exporter
 
 import (
 	"context"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -29,6 +30,7 @@ type serverStatusCollector struct {
 	ctx            context.Context
 	client         *mongo.Client
 	compatibleMode bool
+	remaps         *compatibleRemaps
 	logger         *logrus.Logger
 	topologyInfo   labelsGetter
 }
@@ -37,12 +39,13 @@ func (d *serverStatusCollector) Describe(ch chan<- *prometheus.Desc) {
 	prometheus.DescribeByCollect(d, ch)
 }
 
-func (d *serverStatusCollector) Collect(ch chan<- prometheus.Metric) {
-	cmd := bson.D{{Key: "serverStatus", Value: "1"}}
-	res := d.client.Database("admin").RunCommand(d.ctx, cmd)
+func (d *serverStatusCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
 
-	var m bson.M
-	if err := res.Decode(&m); err != nil {
+func (d *serverStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := getServerStatus(d.ctx, d.client)
+	if err != nil {
 		ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err)
 		return
 	}
@@ -50,7 +53,56 @@ func (d *serverStatusCollector) Collect(ch chan<- prometheus.Metric) {
 	logrus.Debug("serverStatus result:")
 	debugResult(d.logger, m)
 
-	for _, metric := range makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode) {
+	for _, metric := range makeMetrics("", m, d.topologyInfo.baseLabels(), d.compatibleMode, d.remaps) {
 		ch <- metric
 	}
 }
+
+func getServerStatus(ctx context.Context, client *mongo.Client) (bson.M, error) {
+	cmd := bson.D{{Key: "serverStatus", Value: "1"}}
+	res := client.Database("admin").RunCommand(ctx, cmd)
+
+	var m bson.M
+	if err := res.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// scrapeServerStatus runs serverStatus once per scrape for all the collectors reading it, since it's
+// a heavy command. The first collector needing it runs it, with its own context.
+type scrapeServerStatus struct {
+	client *mongo.Client
+
+	m      sync.Mutex
+	done   bool
+	status bson.M
+	err    error
+}
+
+func newScrapeServerStatus(client *mongo.Client) *scrapeServerStatus {
+	return &scrapeServerStatus{client: client}
+}
+
+// get returns the serverStatus of this scrape. The result must not be modified, the other
+// collectors read it too.
+func (s *scrapeServerStatus) get(ctx context.Context) (bson.M, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.done {
+		return s.status, s.err
+	}
+
+	s.status, s.err = getServerStatus(ctx, s.client)
+
+	// If the collector timed out, the next collector can try with its own context.
+	if s.err != nil && ctx.Err() != nil {
+		return nil, s.err
+	}
+
+	s.done = true
+
+	return s.status, s.err
+}
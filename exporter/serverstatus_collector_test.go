@@ -25,6 +25,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/percona/mongodb_exporter/internal/tu"
 )
@@ -63,3 +66,32 @@ mongodb_metrics_commands_connPoolSync_failed 0` + "\n")
 	err := testutil.CollectAndCompare(c, expected, filter...)
 	assert.NoError(t, err)
 }
+
+func TestScrapeServerStatus(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing listens on this port, so serverStatus fails.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	defer client.Disconnect(ctx) //nolint:errcheck
+
+	s := newScrapeServerStatus(client)
+
+	// A collector that timed out doesn't fail the next ones.
+	timedOut, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = s.get(timedOut)
+	require.Error(t, err)
+	assert.False(t, s.done)
+
+	_, firstErr := s.get(ctx)
+	require.Error(t, firstErr)
+	assert.True(t, s.done)
+
+	// The other collectors get the same result, without running serverStatus again.
+	_, err = s.get(ctx)
+	assert.Equal(t, firstErr, err)
+}
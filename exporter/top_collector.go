@@ -0,0 +1,118 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// topCollector exposes the time spent and the number of operations per collection from the top
+// command. It doesn't work through mongos.
+type topCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	client       *mongo.Client
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+	// Add the database and collection labels besides the namespace label.
+	splitNamespaceLabels bool
+}
+
+func (d *topCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *topCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *topCollector) Collect(ch chan<- prometheus.Metric) {
+	var m bson.M
+
+	cmd := bson.D{{Key: "top", Value: 1}}
+	if err := d.client.Database("admin").RunCommand(d.ctx, cmd).Decode(&m); err != nil {
+		d.logger.Errorf("cannot get top: %s", err)
+		d.fail()
+
+		return
+	}
+
+	totals, ok := m["totals"].(bson.M)
+	if !ok {
+		d.logger.Error("cannot get top: the totals section is missing")
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range topMetrics(totals, d.splitNamespaceLabels, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// topMetrics builds the metrics from the top totals. Each namespace has a document per operation
+// type (total, readLock, queries, insert, etc.) with the time in microseconds and the count.
+func topMetrics(totals bson.M, splitNamespaceLabels bool, labels map[string]string) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(totals))
+
+	for namespace, stats := range totals {
+		// Besides the namespaces, there is a "note" string field.
+		types, ok := stats.(bson.M)
+		if !ok || namespace == "" {
+			continue
+		}
+
+		for opType, opStats := range types {
+			s, ok := opStats.(bson.M)
+			if !ok {
+				continue
+			}
+
+			opLabels := map[string]string{"type": opType}
+			setNamespaceLabels(opLabels, namespace, splitNamespaceLabels)
+
+			defs := []fieldMetric{
+				{
+					path:   []string{"time"},
+					name:   "mongodb_top_total_time_microseconds",
+					help:   "Time spent in the operations on the collection, in microseconds",
+					vt:     prometheus.CounterValue,
+					labels: opLabels,
+				},
+				{
+					path:   []string{"count"},
+					name:   "mongodb_top_count",
+					help:   "Number of operations on the collection",
+					vt:     prometheus.CounterValue,
+					labels: opLabels,
+				},
+			}
+
+			metrics = append(metrics, fieldMetrics(s, defs, labels)...)
+		}
+	}
+
+	return metrics
+}
+
+var _ prometheus.Collector = (*topCollector)(nil)
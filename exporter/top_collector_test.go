@@ -0,0 +1,49 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTopMetrics(t *testing.T) {
+	totals := bson.M{
+		"note": "all times in microseconds",
+		"testdb.testcol": bson.M{
+			"total":   bson.M{"time": int64(2500), "count": int64(12)},
+			"queries": bson.M{"time": int64(1500), "count": int64(10)},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_top_count Number of operations on the collection",
+		"# TYPE mongodb_top_count counter",
+		`mongodb_top_count{namespace="testdb.testcol",rs_nm="rs1",type="queries"} 10`,
+		`mongodb_top_count{namespace="testdb.testcol",rs_nm="rs1",type="total"} 12`,
+		"# HELP mongodb_top_total_time_microseconds Time spent in the operations on the collection, in microseconds",
+		"# TYPE mongodb_top_total_time_microseconds counter",
+		`mongodb_top_total_time_microseconds{namespace="testdb.testcol",rs_nm="rs1",type="queries"} 1500`,
+		`mongodb_top_total_time_microseconds{namespace="testdb.testcol",rs_nm="rs1",type="total"} 2500`,
+	}
+
+	metrics := topMetrics(totals, false, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+}
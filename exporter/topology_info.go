@@ -35,6 +35,8 @@ const (
 	labelClusterID       = "cl_id"
 	labelReplicasetName  = "rs_nm"
 	labelReplicasetState = "rs_state"
+	labelClusterName     = "cl_name"
+	labelMemberRole      = "rs_role"
 
 	typeIsDBGrid                    = "isdbgrid"
 	typeMongos      mongoDBNodeType = "mongos"
@@ -42,6 +44,13 @@ const (
 	typeShardServer mongoDBNodeType = "shardsvr"
 )
 
+type memberRole string
+
+const (
+	roleArbiter memberRole = "arbiter"
+	roleHidden  memberRole = "hidden"
+)
+
 type labelsGetter interface {
 	baseLabels() map[string]string
 	loadLabels(context.Context) error
@@ -104,9 +113,12 @@ func (t *topologyInfo) loadLabels(ctx context.Context) error {
 
 	t.labels[labelClusterRole] = string(nodeType)
 
-	// Standalone instances or mongos instances won't have a replicaset name
+	// Standalone instances or mongos instances won't have a replicaset name. Without privileges to get
+	// the replica set config, the name from isMaster is used.
 	if rs, err := util.ReplicasetConfig(ctx, t.client); err == nil {
 		t.labels[labelReplicasetName] = rs.Config.ID
+	} else if name, err := getSetName(ctx, t.client); err == nil && name != "" {
+		t.labels[labelReplicasetName] = name
 	}
 
 	cid, err := util.ClusterID(ctx, t.client)
@@ -121,9 +133,60 @@ func (t *topologyInfo) loadLabels(ctx context.Context) error {
 		t.labels[labelReplicasetState] = fmt.Sprintf("%d", state)
 	}
 
+	// Only arbiters and hidden members have a role label, the other members keep their labels.
+	if role, err := getMemberRole(ctx, t.client); err == nil && role != "" {
+		t.labels[labelMemberRole] = string(role)
+	}
+
 	return nil
 }
 
+func getSetName(ctx context.Context, client *mongo.Client) (string, error) {
+	md := proto.MasterDoc{}
+	if err := client.Database("admin").RunCommand(ctx, primitive.M{"isMaster": 1}).Decode(&md); err != nil {
+		return "", err
+	}
+
+	name, _ := md.SetName.(string)
+
+	return name, nil
+}
+
+// getMemberRole returns the role of the replica set member if it's an arbiter or a hidden member.
+// Other members and instances that are not in a replica set have no role.
+func getMemberRole(ctx context.Context, client *mongo.Client) (memberRole, error) {
+	var md struct {
+		ArbiterOnly bool `bson:"arbiterOnly"`
+		Hidden      bool `bson:"hidden"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, primitive.M{"isMaster": 1}).Decode(&md); err != nil {
+		return "", err
+	}
+
+	switch {
+	case md.ArbiterOnly:
+		return roleArbiter, nil
+	case md.Hidden:
+		return roleHidden, nil
+	default:
+		return "", nil
+	}
+}
+
+// clusterNameLabels adds the cluster name label to the topology labels. MongoDB has no cluster
+// name, so it's set by the ClusterName option.
+type clusterNameLabels struct {
+	labelsGetter
+	name string
+}
+
+func (c clusterNameLabels) baseLabels() map[string]string {
+	labels := c.labelsGetter.baseLabels()
+	labels[labelClusterName] = c.name
+
+	return labels
+}
+
 func getNodeType(ctx context.Context, client *mongo.Client) (mongoDBNodeType, error) {
 	md := proto.MasterDoc{}
 	if err := client.Database("admin").RunCommand(ctx, primitive.M{"isMaster": 1}).Decode(&md); err != nil {
@@ -41,4 +41,12 @@ func TestTopologyLabels(t *testing.T) {
 	assert.Equal(t, "1", bl[labelReplicasetState])
 	assert.Equal(t, "shardsvr", bl[labelClusterRole])
 	assert.NotEmpty(t, bl[labelClusterID]) // this is variable inside a container
+	// The primary is neither an arbiter nor hidden.
+	assert.NotContains(t, bl, labelMemberRole)
+}
+
+func TestClusterNameLabels(t *testing.T) {
+	ti := clusterNameLabels{labelsGetter: labelsGetterMock{}, name: "prod"}
+	assert.Equal(t, map[string]string{labelClusterName: "prod"}, ti.baseLabels())
+	assert.NoError(t, ti.loadLabels(context.Background()))
 }
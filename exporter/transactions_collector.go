@@ -0,0 +1,87 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// transactionsCollector exposes the multi-document transactions from serverStatus().transactions.
+// The mongos section has other counters so, it's only used in mongod.
+type transactionsCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *transactionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *transactionsCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *transactionsCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range transactionsMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// transactionsMetrics returns no metrics for servers without the transactions section, before 3.6.
+func transactionsMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := make([]fieldMetric, 0, 6) //nolint:gomnd
+
+	for state, field := range map[string]string{"started": "totalStarted", "committed": "totalCommitted", "aborted": "totalAborted"} {
+		defs = append(defs, fieldMetric{
+			path:   []string{"transactions", field},
+			name:   "mongodb_transactions_total",
+			help:   "Number of transactions since the server started, by state",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"state": state},
+		})
+	}
+
+	for state, field := range map[string]string{"active": "currentActive", "inactive": "currentInactive", "open": "currentOpen"} {
+		defs = append(defs, fieldMetric{
+			path:   []string{"transactions", field},
+			name:   "mongodb_transactions_current",
+			help:   "Number of transactions open now, by state. The open ones are the active plus the inactive ones",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"state": state},
+		})
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*transactionsCollector)(nil)
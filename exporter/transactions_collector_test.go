@@ -0,0 +1,60 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTransactionsMetrics(t *testing.T) {
+	m := bson.M{
+		"transactions": bson.M{
+			"retriedCommandsCount":             int64(0),
+			"currentActive":                    int64(1),
+			"currentInactive":                  int64(2),
+			"currentOpen":                      int64(3),
+			"totalAborted":                     int64(4),
+			"totalCommitted":                   int64(10),
+			"totalStarted":                     int64(17),
+			"currentPrepared":                  int64(0),
+			"totalPreparedThenCommitted":       int64(0),
+			"transactionsCollectionWriteCount": int64(0),
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_transactions_current Number of transactions open now, by state. The open ones are the active plus the inactive ones",
+		"# TYPE mongodb_transactions_current gauge",
+		`mongodb_transactions_current{rs_nm="rs1",state="active"} 1`,
+		`mongodb_transactions_current{rs_nm="rs1",state="inactive"} 2`,
+		`mongodb_transactions_current{rs_nm="rs1",state="open"} 3`,
+		"# HELP mongodb_transactions_total Number of transactions since the server started, by state",
+		"# TYPE mongodb_transactions_total counter",
+		`mongodb_transactions_total{rs_nm="rs1",state="aborted"} 4`,
+		`mongodb_transactions_total{rs_nm="rs1",state="committed"} 10`,
+		`mongodb_transactions_total{rs_nm="rs1",state="started"} 17`,
+	}
+
+	metrics := transactionsMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	assert.Empty(t, transactionsMetrics(bson.M{"ok": float64(1)}, nil))
+}
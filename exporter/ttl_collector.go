@@ -0,0 +1,80 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ttlCollector exposes the TTL monitor activity from serverStatus().metrics.ttl. There is no TTL
+// monitor in mongos.
+type ttlCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *ttlCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *ttlCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *ttlCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	for _, metric := range ttlMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+// ttlMetrics returns no metrics for old servers without the metrics.ttl section.
+func ttlMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path: []string{"metrics", "ttl", "deletedDocuments"},
+			name: "mongodb_ttl_deleted_documents_total",
+			help: "Number of documents deleted from collections with a TTL index",
+			vt:   prometheus.CounterValue,
+		},
+		{
+			path: []string{"metrics", "ttl", "passes"},
+			name: "mongodb_ttl_passes_total",
+			help: "Number of times the TTL monitor has removed documents from collections with a TTL index",
+			vt:   prometheus.CounterValue,
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*ttlCollector)(nil)
@@ -0,0 +1,51 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTTLMetrics(t *testing.T) {
+	m := bson.M{
+		"metrics": bson.M{
+			"ttl": bson.M{
+				"deletedDocuments": int64(1234),
+				"passes":           int64(56),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_ttl_deleted_documents_total Number of documents deleted from collections with a TTL index",
+		"# TYPE mongodb_ttl_deleted_documents_total counter",
+		`mongodb_ttl_deleted_documents_total{rs_nm="rs1"} 1234`,
+		"# HELP mongodb_ttl_passes_total Number of times the TTL monitor has removed documents from collections with a TTL index",
+		"# TYPE mongodb_ttl_passes_total counter",
+		`mongodb_ttl_passes_total{rs_nm="rs1"} 56`,
+	}
+
+	metrics := ttlMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Old servers don't have the ttl section.
+	assert.Empty(t, ttlMetrics(bson.M{"metrics": bson.M{}}, nil))
+}
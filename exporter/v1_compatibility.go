@@ -11,12 +11,10 @@ import (
 	"github.com/percona/percona-toolkit/src/go/mongolib/util"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/log"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // ErrInvalidMetricValue cannot create a new metric due to an invalid value.
@@ -821,39 +819,18 @@ func myState(ctx context.Context, client *mongo.Client) (prometheus.Metric, erro
 }
 
 func oplogStatus(ctx context.Context, client *mongo.Client) ([]prometheus.Metric, error) {
-	oplogRS := client.Database("local").Collection("oplog.rs")
-	type oplogRSResult struct {
-		Timestamp primitive.Timestamp `bson:"ts"`
-	}
-	var head, tail oplogRSResult
-	headRes := oplogRS.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{
-		"$natural": -1,
-	}))
-	if headRes.Err() != nil {
-		return nil, headRes.Err()
-	}
-
-	if err := headRes.Decode(&head); err != nil {
-		return nil, err
-	}
-	tailRes := oplogRS.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{
-		"$natural": 1,
-	}))
-	if tailRes.Err() != nil {
-		return nil, tailRes.Err()
-	}
-
-	if err := tailRes.Decode(&tail); err != nil {
+	head, tail, err := oplogTimestamps(ctx, client)
+	if err != nil {
 		return nil, err
 	}
 
 	headDesc := prometheus.NewDesc("mongodb_mongod_replset_oplog_head_timestamp",
 		"The timestamp of the newest change in the oplog", nil, nil)
-	headMetric := prometheus.MustNewConstMetric(headDesc, prometheus.GaugeValue, float64(head.Timestamp.T))
+	headMetric := prometheus.MustNewConstMetric(headDesc, prometheus.GaugeValue, float64(head.T))
 
 	tailDesc := prometheus.NewDesc("mongodb_mongod_replset_oplog_tail_timestamp",
 		"The timestamp of the oldest change in the oplog", nil, nil)
-	tailMetric := prometheus.MustNewConstMetric(tailDesc, prometheus.GaugeValue, float64(tail.Timestamp.T))
+	tailMetric := prometheus.MustNewConstMetric(tailDesc, prometheus.GaugeValue, float64(tail.T))
 
 	return []prometheus.Metric{headMetric, tailMetric}, nil
 }
@@ -989,7 +966,7 @@ func mongosMetrics(ctx context.Context, client *mongo.Client, l *logrus.Logger)
 		metrics = append(metrics, metric)
 	}
 
-	ms, err = changelog10m(ctx, client)
+	ms, err = changelog10m(ctx, client, l)
 	if err != nil {
 		l.Errorf("cannot create metric for changelog: %s", err)
 	} else {
@@ -1195,7 +1172,7 @@ type ShardingChangelogStats struct {
 	Items *[]ShardingChangelogSummary
 }
 
-func changelog10m(ctx context.Context, client *mongo.Client) ([]prometheus.Metric, error) {
+func changelog10m(ctx context.Context, client *mongo.Client, l *logrus.Logger) ([]prometheus.Metric, error) {
 	var metrics []prometheus.Metric
 
 	coll := client.Database("config").Collection("changelog")
@@ -1212,7 +1189,7 @@ func changelog10m(ctx context.Context, client *mongo.Client) ([]prometheus.Metri
 	for c.Next(ctx) {
 		s := &ShardingChangelogSummary{}
 		if err := c.Decode(s); err != nil {
-			log.Error(err)
+			l.Errorf("cannot decode sharding changelog summary: %s", err)
 			continue
 		}
 
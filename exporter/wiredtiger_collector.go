@@ -0,0 +1,136 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// wiredTigerCollector exposes the cache stats from serverStatus().wiredTiger.cache with stable
+// metric names, independently of the compatible mode.
+type wiredTigerCollector struct {
+	collectFailure
+
+	ctx          context.Context
+	serverStatus *scrapeServerStatus
+	logger       *logrus.Logger
+	topologyInfo labelsGetter
+}
+
+func (d *wiredTigerCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(d, ch)
+}
+
+func (d *wiredTigerCollector) setContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *wiredTigerCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := d.serverStatus.get(d.ctx)
+	if err != nil {
+		d.logger.Errorf("cannot get serverStatus: %s", err)
+		d.fail()
+
+		return
+	}
+
+	// The section doesn't exist for other storage engines, like mmapv1, nor in mongos.
+	if _, ok := m["wiredTiger"]; !ok {
+		d.logger.Debug("serverStatus has no wiredTiger section")
+
+		return
+	}
+
+	for _, metric := range wiredTigerCacheMetrics(m, d.topologyInfo.baseLabels()) {
+		ch <- metric
+	}
+}
+
+func wiredTigerCacheMetrics(m bson.M, labels map[string]string) []prometheus.Metric {
+	defs := []fieldMetric{
+		{
+			path:   []string{"wiredTiger", "cache", "bytes currently in the cache"},
+			name:   "mongodb_wiredtiger_cache_bytes",
+			help:   "Size of the data currently in the WiredTiger cache",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "total"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "tracked dirty bytes in the cache"},
+			name:   "mongodb_wiredtiger_cache_bytes",
+			help:   "Size of the data currently in the WiredTiger cache",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "dirty"},
+		},
+		{
+			path: []string{"wiredTiger", "cache", "maximum bytes configured"},
+			name: "mongodb_wiredtiger_cache_max_bytes",
+			help: "Maximum size of the WiredTiger cache",
+			vt:   prometheus.GaugeValue,
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "pages currently held in the cache"},
+			name:   "mongodb_wiredtiger_cache_pages",
+			help:   "Number of pages currently held in the WiredTiger cache",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "total"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "tracked dirty pages in the cache"},
+			name:   "mongodb_wiredtiger_cache_pages",
+			help:   "Number of pages currently held in the WiredTiger cache",
+			vt:     prometheus.GaugeValue,
+			labels: map[string]string{"type": "dirty"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "modified pages evicted"},
+			name:   "mongodb_wiredtiger_cache_evicted_pages_total",
+			help:   "Number of pages evicted from the WiredTiger cache",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": "modified"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "unmodified pages evicted"},
+			name:   "mongodb_wiredtiger_cache_evicted_pages_total",
+			help:   "Number of pages evicted from the WiredTiger cache",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": "unmodified"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "bytes read into cache"},
+			name:   "mongodb_wiredtiger_cache_io_bytes_total",
+			help:   "Number of bytes read into or written from the WiredTiger cache",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": "read"},
+		},
+		{
+			path:   []string{"wiredTiger", "cache", "bytes written from cache"},
+			name:   "mongodb_wiredtiger_cache_io_bytes_total",
+			help:   "Number of bytes read into or written from the WiredTiger cache",
+			vt:     prometheus.CounterValue,
+			labels: map[string]string{"type": "written"},
+		},
+	}
+
+	return fieldMetrics(m, defs, labels)
+}
+
+var _ prometheus.Collector = (*wiredTigerCollector)(nil)
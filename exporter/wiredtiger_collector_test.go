@@ -0,0 +1,59 @@
+// mongodb_exporter
+// Copyright (C) 2017 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/percona/exporter_shared/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWiredTigerCacheMetrics(t *testing.T) {
+	m := bson.M{
+		"wiredTiger": bson.M{
+			"cache": bson.M{
+				"bytes currently in the cache":     int64(2048),
+				"tracked dirty bytes in the cache": int64(512),
+				"maximum bytes configured":         int64(4096),
+				"modified pages evicted":           int32(7),
+				"unmodified pages evicted":         int32(9),
+			},
+		},
+	}
+
+	want := []string{
+		"# HELP mongodb_wiredtiger_cache_bytes Size of the data currently in the WiredTiger cache",
+		"# TYPE mongodb_wiredtiger_cache_bytes gauge",
+		`mongodb_wiredtiger_cache_bytes{rs_nm="rs1",type="dirty"} 512`,
+		`mongodb_wiredtiger_cache_bytes{rs_nm="rs1",type="total"} 2048`,
+		"# HELP mongodb_wiredtiger_cache_evicted_pages_total Number of pages evicted from the WiredTiger cache",
+		"# TYPE mongodb_wiredtiger_cache_evicted_pages_total counter",
+		`mongodb_wiredtiger_cache_evicted_pages_total{rs_nm="rs1",type="modified"} 7`,
+		`mongodb_wiredtiger_cache_evicted_pages_total{rs_nm="rs1",type="unmodified"} 9`,
+		"# HELP mongodb_wiredtiger_cache_max_bytes Maximum size of the WiredTiger cache",
+		"# TYPE mongodb_wiredtiger_cache_max_bytes gauge",
+		`mongodb_wiredtiger_cache_max_bytes{rs_nm="rs1"} 4096`,
+	}
+
+	metrics := wiredTigerCacheMetrics(m, map[string]string{labelReplicasetName: "rs1"})
+	assert.Equal(t, want, helpers.Format(metrics))
+
+	// Other storage engines don't have the wiredTiger section.
+	assert.Empty(t, wiredTigerCacheMetrics(bson.M{"storageEngine": bson.M{"name": "mmapv1"}}, nil))
+}
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alecthomas/kong"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/mongodb_exporter/exporter"
 )
 
 func TestBuildExporter(t *testing.T) {
@@ -25,3 +36,98 @@ func TestBuildExporter(t *testing.T) {
 	_, err := buildExporter(opts)
 	assert.NoError(t, err)
 }
+
+func TestSplitList(t *testing.T) {
+	assert.Nil(t, splitList(""))
+	assert.Equal(t, []string{"db1", "db2"}, splitList("db1, db2,,"))
+}
+
+func TestParseCollectorTimeouts(t *testing.T) {
+	timeouts, err := parseCollectorTimeouts("diagnosticdata=5s, collstats=0")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"diagnosticdata": 5 * time.Second, "collstats": 0}, timeouts)
+
+	timeouts, err = parseCollectorTimeouts("")
+	require.NoError(t, err)
+	assert.Nil(t, timeouts)
+
+	_, err = parseCollectorTimeouts("diagnosticdata")
+	assert.EqualError(t, err, `invalid collector timeout "diagnosticdata", it must be collector=timeout`)
+
+	_, err = parseCollectorTimeouts("diagnosticdata=5")
+	assert.Error(t, err)
+}
+
+func TestBuildInfoCollector(t *testing.T) {
+	version, commit, buildDate = "0.20.0", "abc123", "2021-05-10"
+
+	defer func() {
+		version, commit, buildDate = "", "", ""
+	}()
+
+	want := strings.NewReader(`# HELP mongodb_exporter_build_info A metric with a constant '1' value labeled by the version, commit, build date and Go version of the exporter
+# TYPE mongodb_exporter_build_info gauge
+mongodb_exporter_build_info{build_date="2021-05-10",commit="abc123",go_version="` + runtime.Version() + `",version="0.20.0"} 1
+`)
+	assert.NoError(t, testutil.CollectAndCompare(buildInfoCollector(), want))
+}
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mongodb_exporter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	config := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(config, []byte(`
+mongodb:
+  uri: mongodb://127.0.0.1:17001
+  collstats-colls: [db1.col1, db1.col2]
+  collstats-max-indexes: 10
+enable.dbstats: true
+log.level: warn
+custom_queries:
+  - name: orders
+    database: shop
+    collection: orders
+    pipeline: '[{"$group": {"_id": "$status", "count": {"$sum": 1}}}]'
+    labels:
+      status: _id
+    metrics:
+      - field: count
+        name: mongodb_shop_orders
+        help: Number of orders by status
+`), 0o600))
+
+	parse := func(args ...string) (GlobalFlags, error) {
+		var opts GlobalFlags
+		k, err := kong.New(&opts, kong.Configuration(yamlConfig))
+		require.NoError(t, err)
+		_, err = k.Parse(args)
+
+		return opts, err
+	}
+
+	opts, err := parse("--config.file", config, "--log.level", "debug")
+	require.NoError(t, err)
+	assert.Equal(t, "mongodb://127.0.0.1:17001", opts.URI)
+	assert.Equal(t, "db1.col1,db1.col2", opts.CollStatsCollections)
+	assert.Equal(t, 10, opts.CollStatsMaxIndexes)
+	assert.True(t, opts.EnableDBStats)
+	// The command line takes precedence.
+	assert.Equal(t, "debug", opts.LogLevel)
+
+	queries, err := loadCustomQueries(string(opts.ConfigFile))
+	require.NoError(t, err)
+	assert.Equal(t, []exporter.CustomQuery{{
+		Name:       "orders",
+		Database:   "shop",
+		Collection: "orders",
+		Pipeline:   `[{"$group": {"_id": "$status", "count": {"$sum": 1}}}]`,
+		Labels:     map[string]string{"status": "_id"},
+		Metrics:    []exporter.CustomQueryMetric{{Field: "count", Name: "mongodb_shop_orders", Help: "Number of orders by status"}},
+	}}, queries)
+
+	require.NoError(t, ioutil.WriteFile(config, []byte("enable.dbstat: true\nmongodb.url: x\n"), 0o600))
+	_, err = parse("--config.file", config)
+	assert.EqualError(t, err, "unknown config file keys: enable.dbstat, mongodb.url")
+}